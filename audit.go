@@ -0,0 +1,142 @@
+package babyenv
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+)
+
+// source describes where the value of a field came from
+type source int
+
+const (
+	sourceZero source = iota
+	sourceEnv
+	sourceDefault
+	sourceExisting
+	sourceSkipped
+	sourceFlag
+)
+
+func (s source) String() string {
+	switch s {
+	case sourceEnv:
+		return "env"
+	case sourceDefault:
+		return "default"
+	case sourceExisting:
+		return "existing"
+	case sourceSkipped:
+		return "skipped"
+	case sourceFlag:
+		return "flag"
+	default:
+		return "zero"
+	}
+}
+
+// Report describes where the values of a struct's fields came from. Each
+// entry is the name of an environment variable.
+type Report struct {
+	// Variables that were read from the environment, or whose value came
+	// from a flag given with WithFlagOverrides
+	Read []string
+
+	// Variables that were unset, so their field was given its default
+	Defaulted []string
+
+	// Variables that were unset and had no default, leaving their field
+	// with its zero value
+	Missing []string
+
+	// Variables whose field was left untouched, either because it already
+	// held a value and WithRespectExistingValues was given, or because its
+	// type is unsupported and WithSkipUnsupported was given
+	Skipped []string
+}
+
+// ParseReport parses a struct like Parse and also returns a Report describing
+// which variables were read, defaulted, missing or skipped. The report covers
+// the fields parsed before any error was encountered.
+func ParseReport(cfg interface{}, opts ...Option) (Report, error) {
+	o := newOptions(opts)
+	o.report = &Report{}
+	err := parse(cfg, o)
+	return *o.report, err
+}
+
+// ResolveAll works out the value of every variable in a struct, from the
+// environment or from defaults, and returns them keyed by variable name with
+// any prefix applied, such as for passing on to a subprocess. The struct
+// itself isn't touched; only its type is used. Map fields named with a
+// pattern, such as ROUTE_*, contribute each variable they matched. Variables
+// that are unset and have no default are left out. Required variables that
+// are missing are an error, as with Parse.
+func ResolveAll(cfg interface{}, opts ...Option) (map[string]string, error) {
+	ref, err := structValue(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	o.report = &Report{}
+	if err := parse(reflect.New(ref.Type()).Interface(), o); err != nil {
+		return nil, err
+	}
+
+	vars := make(map[string]string, len(o.report.Read)+len(o.report.Defaulted))
+	for _, name := range append(o.report.Read, o.report.Defaulted...) {
+
+		// Patterns such as ROUTE_* stand for each of the variables they
+		// matched
+		if matched, ok := o.matched[name]; ok {
+			for k, v := range matched {
+				vars[k] = v
+			}
+			continue
+		}
+		vars[name] = o.resolved[name]
+	}
+	return vars, nil
+}
+
+// Record the value of a field, so later defaults can refer to it, and where it
+// came from, if we're auditing, logging or building a report.
+func (o *options) record(info *fieldInfo, val string, src source) {
+	o.resolved[o.envName(info)] = val
+	if o.report != nil {
+		o.report.add(o.envName(info), src)
+	}
+	if o.audit == nil && o.logger == nil {
+		return
+	}
+	if info.secret {
+		val = redacted
+	}
+	if o.audit != nil {
+		fmt.Fprintf(o.audit, "%s=%s (%s)\n", o.envName(info), val, src)
+	}
+	if o.logger != nil {
+		o.logger.Debug("resolved environment variable",
+			slog.String("name", o.envName(info)),
+			slog.String("field", info.name),
+			slog.String("value", val),
+			slog.String("source", src.String()),
+			slog.Bool("defaulted", src == sourceDefault),
+		)
+	}
+}
+
+// Add a variable to the category matching its source.
+func (r *Report) add(name string, src source) {
+	switch src {
+	case sourceEnv, sourceFlag:
+		r.Read = append(r.Read, name)
+	case sourceDefault:
+		r.Defaulted = append(r.Defaulted, name)
+	case sourceExisting, sourceSkipped:
+		r.Skipped = append(r.Skipped, name)
+	default:
+		r.Missing = append(r.Missing, name)
+	}
+}
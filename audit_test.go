@@ -0,0 +1,143 @@
+package babyenv
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	type config struct {
+		Name   string `env:"NAME"`
+		Port   int    `env:"PORT" default:"8000"`
+		Debug  bool   `env:"DEBUG"`
+		APIKey string `env:"API_KEY" secret:"true"`
+	}
+
+	env := MapLookuper{"NAME": "Jane", "API_KEY": "hunter2"}
+
+	var buf bytes.Buffer
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithAudit(&buf)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := "NAME=Jane (env)\n" +
+		"PORT=8000 (default)\n" +
+		"DEBUG= (zero)\n" +
+		"API_KEY=**** (env)\n"
+
+	if buf.String() != expected {
+		t.Errorf("unexpected audit output; expected %#v, got %#v", expected, buf.String())
+	}
+}
+
+func TestParseReport(t *testing.T) {
+	type config struct {
+		Name    string         `env:"NAME"`
+		Port    int            `env:"PORT" default:"8000"`
+		Debug   bool           `env:"DEBUG"`
+		Workers int            `env:"WORKERS"`
+		Chan    chan string    `env:"CHAN"`
+		Hosts   []string       `env:"HOSTS"`
+		Limits  map[string]int `env:"LIMITS" default:"a=1"`
+	}
+
+	env := MapLookuper{"NAME": "Jane", "HOSTS": "a,b"}
+
+	cfg := config{Workers: 4}
+	report, err := ParseReport(&cfg, WithLookuper(env), WithRespectExistingValues(), WithSkipUnsupported())
+	if err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := Report{
+		Read:      []string{"NAME", "HOSTS"},
+		Defaulted: []string{"PORT", "LIMITS"},
+		Missing:   []string{"DEBUG"},
+		Skipped:   []string{"WORKERS", "CHAN"},
+	}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("unexpected report; expected %#v, got %#v", expected, report)
+	}
+	if cfg.Name != "Jane" || cfg.Port != 8000 {
+		t.Errorf("expected struct to be populated; got %#v", cfg)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	type config struct {
+		Name   string `env:"NAME"`
+		Port   int    `env:"PORT" default:"8000"`
+		APIKey string `env:"API_KEY" secret:"true" deprecated:"TOKEN"`
+	}
+
+	env := MapLookuper{"NAME": "Jane", "TOKEN": "hunter2"}
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithLogger(logger)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := `level=DEBUG msg="resolved environment variable" name=NAME field=Name value=Jane source=env defaulted=false
+level=DEBUG msg="resolved environment variable" name=PORT field=Port value=8000 source=default defaulted=true
+level=WARN msg="deprecated environment variable used" field=APIKey name=TOKEN replacement=API_KEY
+level=DEBUG msg="resolved environment variable" name=API_KEY field=APIKey value=**** source=env defaulted=false
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected log output; expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestResolveAll(t *testing.T) {
+	type appConfig struct {
+		Host    string            `env:"HOST,required"`
+		Port    int               `env:"PORT" default:"8000"`
+		Debug   bool              `env:"DEBUG"`
+		Workers int               `env:"WORKERS" default:"4"`
+		Routes  map[string]string `env:"ROUTE_*"`
+	}
+
+	env := MapLookuper{"APP_HOST": "localhost", "APP_WORKERS": "8", "APP_ROUTE_A": "1", "APP_ROUTE_B": "2"}
+
+	cfg := appConfig{Host: "untouched"}
+	vars, err := ResolveAll(&cfg, WithLookuper(env), WithAutoPrefix())
+	if err != nil {
+		t.Errorf("error while resolving: %v", err)
+		return
+	}
+
+	expected := map[string]string{
+		"APP_HOST":    "localhost",
+		"APP_PORT":    "8000",
+		"APP_WORKERS": "8",
+		"APP_ROUTE_A": "1",
+		"APP_ROUTE_B": "2",
+	}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("unexpected variables; expected %#v, got %#v", expected, vars)
+	}
+	if cfg.Host != "untouched" || cfg.Port != 0 {
+		t.Errorf("expected the struct to be left alone; got %#v", cfg)
+	}
+
+	if _, err := ResolveAll(cfg, WithLookuper(MapLookuper{})); !errors.Is(err, ErrorMissingRequired) {
+		t.Errorf("expected a missing required variable to be an error; got %v", err)
+	}
+}
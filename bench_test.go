@@ -0,0 +1,83 @@
+package babyenv
+
+import "testing"
+
+func BenchmarkParse(b *testing.B) {
+	b.Run("strings", func(b *testing.B) {
+		type config struct {
+			A string `env:"A"`
+			B string `env:"B"`
+			C string `env:"C"`
+			D string `env:"D"`
+			E string `env:"E"`
+			F string `env:"F"`
+			G string `env:"G"`
+			H string `env:"H"`
+		}
+
+		env := MapLookuper{
+			"A": "aaa", "B": "bbb", "C": "ccc", "D": "ddd",
+			"E": "eee", "F": "fff", "G": "ggg", "H": "hhh",
+		}
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var cfg config
+			if err := Parse(&cfg, WithLookuper(env)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("mixed", func(b *testing.B) {
+		type database struct {
+			Host string `env:"DB_HOST" default:"localhost"`
+			Port int    `env:"DB_PORT" default:"5432"`
+		}
+		type config struct {
+			Name     string            `env:"NAME,required"`
+			Env      string            `env:"ENV" default:"development"`
+			Host     string            `env:"HOST" default:"0.0.0.0"`
+			Port     int               `env:"PORT" default:"8000"`
+			Workers  int               `env:"WORKERS"`
+			MaxConns int64             `env:"MAX_CONNS"`
+			Debug    bool              `env:"DEBUG"`
+			Ratio    float64           `env:"RATIO"`
+			Scale    float32           `env:"SCALE" default:"1.5"`
+			Hosts    []string          `env:"HOSTS"`
+			Weights  []float64         `env:"WEIGHTS" sep:";"`
+			Limits   map[string]int    `env:"LIMITS"`
+			Labels   map[string]string `env:"LABELS" default:"team=core"`
+			RGB      [3]int            `env:"RGB"`
+			Token    []byte            `env:"TOKEN"`
+			APIKey   string            `env:"API_KEY" secret:"true"`
+			Region   *string           `env:"REGION"`
+			Retries  *int              `env:"RETRIES" default:"3"`
+			Database database
+		}
+
+		env := MapLookuper{
+			"NAME":      "app",
+			"WORKERS":   "16",
+			"MAX_CONNS": "1_000",
+			"DEBUG":     "true",
+			"RATIO":     "0.25",
+			"HOSTS":     "a,b,c",
+			"WEIGHTS":   "0.5;0.25;0.25",
+			"LIMITS":    "a=1,b=2",
+			"RGB":       "255,128,0",
+			"TOKEN":     "xyz",
+			"API_KEY":   "hunter2",
+			"REGION":    "eu-west-1",
+			"DB_HOST":   "db.internal",
+		}
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var cfg config
+			if err := Parse(&cfg, WithLookuper(env)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package babyenv
+
+import (
+	"reflect"
+	"sync"
+)
+
+// fieldInfo holds what we know about a struct field from its tags, so we don't
+// have to work it out again every time a struct of the same type is parsed
+type fieldInfo struct {
+	index      int
+	name       string
+	typ        reflect.Type
+	tags       reflect.StructTag
+	exported   bool
+	tagged     bool
+	nested     bool
+	envVarName string
+	opts       envTagOptions
+	secret     bool
+	immutable  bool
+	defaultVal string
+	envPrefix  string
+
+	// Problem with the `env` tag, reported when the field is parsed
+	tagErr error
+}
+
+// Field metadata for each struct type we've seen, keyed by reflect.Type
+var fieldCache sync.Map
+
+// Get the metadata for the fields of a struct type, building and caching it
+// if we haven't come across the type before. Fields tagged `env:"-"` are left
+// out entirely.
+func cachedFields(t reflect.Type) []*fieldInfo {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]*fieldInfo)
+	}
+
+	fields := make([]*fieldInfo, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		structField := t.Field(i)
+
+		tagVal := structField.Tag.Get("env")
+		if tagVal == "-" {
+			continue
+		}
+
+		info := &fieldInfo{
+			index:    i,
+			name:     structField.Name,
+			typ:      structField.Type,
+			tags:     structField.Tag,
+			exported: structField.PkgPath == "",
+			tagged:   tagVal != "",
+		}
+
+		if info.tagged {
+			// The tag we're looking at will look something like one of
+			// these:
+			//
+			//     `env:"NAME"`
+			//     `env:"NAME,required"`
+			//
+			// Here we sort out the name from the options.
+			info.envVarName, info.opts, info.tagErr = parseEnvTag(tagVal)
+		} else {
+			// Untagged fields are either nested structs or, with
+			// WithAutoNames, read from a variable named after the field
+			info.nested = isNestedStruct(structField.Type)
+			info.envVarName = upperSnake(structField.Name)
+		}
+
+		// Values of secret fields are never included in errors or output
+		info.secret = structField.Tag.Get("secret") == "true"
+
+		info.defaultVal = structField.Tag.Get("default")
+
+		// Immutable fields can't be changed by reloading
+		info.immutable = structField.Tag.Get("immutable") == "true"
+
+		// Nested structs can add their own prefix to the names of their
+		// fields, so the same type can be used more than once
+		info.envPrefix = structField.Tag.Get("envPrefix")
+
+		fields = append(fields, info)
+	}
+
+	actual, _ := fieldCache.LoadOrStore(t, fields)
+	return actual.([]*fieldInfo)
+}
+
+// Report whether an untagged field of the given type should be parsed as a
+// nested struct. Struct types that we know how to set directly aren't.
+func isNestedStruct(t reflect.Type) bool {
+	switch t {
+	case bigIntType, bigFloatType, nullStringType, nullInt64Type, nullBoolType, nullFloat64Type:
+		return false
+	}
+	if isUnmarshaler(t) || isTextUnmarshaler(t) {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// The outcome of validating the defaults of a struct type. The prefix is part
+// of the key because it appears in errors.
+type defaultKey struct {
+	typ    reflect.Type
+	prefix string
+}
+
+type defaultCheck struct {
+	once sync.Once
+	err  error
+}
+
+// Validate the defaults of a struct type the first time it's seen with these
+// options, remembering the result so that parsing the same type again, even
+// from several goroutines, doesn't repeat the work.
+func (o *options) validateDefaultsOnce(t reflect.Type) error {
+	v, _ := o.checkedDefaults.LoadOrStore(defaultKey{t, o.prefix}, &defaultCheck{})
+	check := v.(*defaultCheck)
+	check.once.Do(func() {
+		check.err = validateDefaults(t, o)
+	})
+	return check.err
+}
@@ -0,0 +1,77 @@
+package babyenv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFieldCache(t *testing.T) {
+	type first struct {
+		A string `env:"A" default:"first"`
+		B int    `env:"B"`
+	}
+	type second struct {
+		B string `env:"B"`
+		A int    `env:"A,required"`
+	}
+
+	env := MapLookuper{"A": "16", "B": "32"}
+
+	for i := 0; i < 2; i++ {
+		var f first
+		if err := Parse(&f, WithLookuper(MapLookuper{"B": "32"})); err != nil {
+			t.Errorf("error while parsing: %v", err)
+			return
+		}
+		if f.A != "first" || f.B != 32 {
+			t.Errorf("failed parsing first struct on pass %d; got %#v", i, f)
+		}
+
+		var s second
+		if err := Parse(&s, WithLookuper(env)); err != nil {
+			t.Errorf("error while parsing: %v", err)
+			return
+		}
+		if s.A != 16 || s.B != "32" {
+			t.Errorf("failed parsing second struct on pass %d; got %#v", i, s)
+		}
+	}
+
+	for _, typ := range []reflect.Type{reflect.TypeOf(first{}), reflect.TypeOf(second{})} {
+		if _, ok := fieldCache.Load(typ); !ok {
+			t.Errorf("expected %v to be cached", typ)
+		}
+	}
+}
+
+func BenchmarkFieldCache(b *testing.B) {
+	type config struct {
+		A string  `env:"A"`
+		B int     `env:"B,required"`
+		C bool    `env:"C" default:"true"`
+		D float64 `env:"D"`
+		E []int   `env:"E" sep:";"`
+	}
+
+	env := MapLookuper{"A": "xxx", "B": "16", "D": "0.5", "E": "1;2;3"}
+	typ := reflect.TypeOf(config{})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var cfg config
+			if err := Parse(&cfg, WithLookuper(env)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			fieldCache.Delete(typ)
+			var cfg config
+			if err := Parse(&cfg, WithLookuper(env)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package babyenv
+
+import "reflect"
+
+// FieldDescription describes the environment variable read by a struct field.
+type FieldDescription struct {
+	// Name of the environment variable, including any prefix
+	Name string
+
+	// Path to the struct field, including any structs it's nested in, such
+	// as Database.Host
+	Field string
+
+	// Go type of the field, such as "int" or "[]string"
+	Type string
+
+	// Value of the `default` tag, redacted if the field is a secret
+	Default string
+
+	// Whether the variable is always required. Conditional requirements
+	// set with required_if aren't reflected here.
+	Required bool
+
+	// Value of the `desc` tag
+	Desc string
+
+	// Whether the field is marked as a secret
+	Secret bool
+}
+
+// Describe lists the environment variables read by a struct, including those
+// of nested structs, without reading the environment or altering the struct.
+// Either a struct or a pointer to one may be given. It's handy for generating
+// documentation or help output.
+func Describe(cfg interface{}, opts ...Option) ([]FieldDescription, error) {
+	ref, err := structValue(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	o.setAutoPrefix(ref.Type())
+	return describeFields(ref.Type(), o), nil
+}
+
+// Describe the tagged fields of a struct type, recursing into nested structs.
+func describeFields(t reflect.Type, o *options) []FieldDescription {
+	if o.visiting[t] {
+		return nil
+	}
+	o.visiting[t] = true
+	defer delete(o.visiting, t)
+
+	var fields []FieldDescription
+
+	for _, info := range cachedFields(t) {
+		if !o.hasEnvVar(info) {
+			nested := info.typ
+			if nested.Kind() == reflect.Ptr {
+				nested = nested.Elem()
+			}
+			if nested.Kind() == reflect.Struct && info.exported {
+				leave := o.enterNested(info)
+				fields = append(fields, describeFields(nested, o)...)
+				leave()
+			}
+			continue
+		}
+
+		// Defaults of secret fields are as sensitive as their values
+		defaultVal := o.defaultFor(info)
+		if info.secret && defaultVal != "" {
+			defaultVal = redacted
+		}
+
+		fields = append(fields, FieldDescription{
+			Name:     o.envName(info),
+			Field:    o.fieldPath(info),
+			Type:     info.typ.String(),
+			Default:  defaultVal,
+			Required: info.opts.required,
+			Desc:     info.tags.Get("desc"),
+			Secret:   info.secret,
+		})
+	}
+
+	return fields
+}
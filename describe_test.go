@@ -0,0 +1,45 @@
+package babyenv
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	type database struct {
+		Host string `env:"DB_HOST" default:"localhost"`
+	}
+	type config struct {
+		Name     string `env:"NAME,required" desc:"the display name"`
+		APIKey   string `env:"API_KEY" secret:"true" default:"hunter2"`
+		Ignored  string `env:"-"`
+		Database *database
+	}
+
+	expected := []FieldDescription{
+		{Name: "NAME", Field: "Name", Type: "string", Required: true, Desc: "the display name"},
+		{Name: "API_KEY", Field: "APIKey", Type: "string", Default: "****", Secret: true},
+		{Name: "DB_HOST", Field: "Database.Host", Type: "string", Default: "localhost"},
+	}
+
+	var cfg config
+	for _, arg := range []interface{}{cfg, &cfg} {
+		fields, err := Describe(arg)
+		if err != nil {
+			t.Errorf("error while describing %T: %v", arg, err)
+			continue
+		}
+		if !reflect.DeepEqual(fields, expected) {
+			t.Errorf("unexpected description of %T; expected %#v, got %#v", arg, expected, fields)
+		}
+	}
+
+	if cfg.Database != nil {
+		t.Error("expected Describe not to alter the struct")
+	}
+
+	if _, err := Describe(42); !errors.Is(err, ErrorNotAStructPointer) {
+		t.Errorf("expected ErrorNotAStructPointer describing a non-struct; got %v", err)
+	}
+}
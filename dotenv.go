@@ -0,0 +1,195 @@
+package babyenv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrorDotEnvSyntax is used when a line in a .env source can't be parsed
+type ErrorDotEnvSyntax struct {
+	Line int
+	Msg  string
+}
+
+// Error implements the error interface
+func (e *ErrorDotEnvSyntax) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// ParseReader reads variables in the .env format from r and then parses the
+// struct using them, without touching the process environment. Each line
+// should be in the form KEY=VALUE. Blank lines and lines beginning with a #
+// are ignored, and values can be wrapped in single or double quotes. Double
+// quoted values may contain escape sequences such as \n.
+//
+//     # Database settings
+//     export DATABASE_URL="postgres://localhost/app"
+//     DATABASE_POOL=16
+func ParseReader(r io.Reader, cfg interface{}, opts ...Option) error {
+	env, err := readDotEnv(r)
+	if err != nil {
+		return err
+	}
+	return Parse(cfg, append(opts, WithLookuper(env))...)
+}
+
+// ParseFiles reads variables from each of the given .env files in turn and
+// then parses the struct using them, with variables in later files overriding
+// those in earlier ones:
+//
+//     err := babyenv.ParseFiles([]string{".env", ".env.local"}, &cfg)
+//
+// Files that don't exist are skipped unless WithRequiredFiles is given. By
+// default only the files are read; with WithEnvFallback, variables they don't
+// define are looked up in the process environment, or the Lookuper given with
+// WithLookuper.
+func ParseFiles(paths []string, cfg interface{}, opts ...Option) error {
+	o := newOptions(opts)
+
+	env := MapLookuper{}
+	for _, path := range paths {
+		vars, err := readDotEnvFile(path)
+		if errors.Is(err, fs.ErrNotExist) && !o.requireFiles {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for k, v := range vars {
+			env[k] = v
+		}
+	}
+
+	var l Lookuper = env
+	if o.envFallback {
+		l = fallbackLookuper{env, o.lookuper}
+	}
+	return Parse(cfg, append(opts, WithLookuper(l))...)
+}
+
+// Read .env formatted variables from a file, naming the file in syntax errors.
+func readDotEnvFile(path string) (MapLookuper, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	env, err := readDotEnv(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return env, nil
+}
+
+// fallbackLookuper looks variables up in a map, falling back to another
+// Lookuper for those it doesn't have.
+type fallbackLookuper struct {
+	vars     MapLookuper
+	fallback Lookuper
+}
+
+func (f fallbackLookuper) LookupEnv(name string) (string, bool) {
+	if v, ok := f.vars[name]; ok {
+		return v, true
+	}
+	return f.fallback.LookupEnv(name)
+}
+
+func (f fallbackLookuper) Keys() []string {
+	keys := f.vars.Keys()
+	if enum, ok := f.fallback.(Enumerator); ok {
+		for _, k := range enum.Keys() {
+			if _, ok := f.vars[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+// Read .env formatted variables into a map. Later definitions of a variable
+// override earlier ones.
+func readDotEnv(r io.Reader) (MapLookuper, error) {
+	env := MapLookuper{}
+	scanner := bufio.NewScanner(r)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, &ErrorDotEnvSyntax{lineNum, "expected KEY=VALUE"}
+		}
+
+		key := strings.TrimSpace(line[:i])
+		if key == "" {
+			return nil, &ErrorDotEnvSyntax{lineNum, "missing variable name"}
+		}
+
+		val, err := parseDotEnvValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, &ErrorDotEnvSyntax{lineNum, err.Error()}
+		}
+
+		env[key] = val
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// Parse the value portion of a line, handling quotes and trailing comments.
+func parseDotEnvValue(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	switch q := s[0]; q {
+	case '"', '\'':
+		end := closingQuote(s, q)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote in %s", s)
+		}
+		if rest := strings.TrimSpace(s[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected characters after quoted value: %s", rest)
+		}
+		if q == '\'' {
+			return s[1:end], nil
+		}
+		return strconv.Unquote(s[:end+1])
+	}
+
+	// Unquoted values may be followed by a comment
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s, nil
+}
+
+// Find the index of the quote closing the one at the start of s, skipping
+// over escaped quotes in double quoted strings. Returns -1 if there isn't one.
+func closingQuote(s string, q byte) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && q == '"':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
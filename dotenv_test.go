@@ -0,0 +1,119 @@
+package babyenv
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseReader(t *testing.T) {
+	type config struct {
+		A string `env:"A"`
+		B string `env:"B"`
+		C string `env:"C"`
+		D int    `env:"D"`
+		E string `env:"E"`
+		F string `env:"F" default:"fff"`
+	}
+
+	src := `
+# This is a comment
+A="double quoted\nwith a newline"
+B='single quoted \n literal'
+
+export C=unquoted # with a comment
+D=16
+E=
+`
+
+	var cfg config
+	if err := ParseReader(strings.NewReader(src), &cfg); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != "double quoted\nwith a newline" {
+		t.Errorf("failed parsing double quoted value; got %#v", cfg.A)
+	}
+	if cfg.B != `single quoted \n literal` {
+		t.Errorf("failed parsing single quoted value; got %#v", cfg.B)
+	}
+	if cfg.C != "unquoted" {
+		t.Errorf("failed parsing unquoted value; got %#v", cfg.C)
+	}
+	if cfg.D != 16 {
+		t.Errorf("failed parsing int; expected %#v, got %#v", 16, cfg.D)
+	}
+	if cfg.E != "" {
+		t.Errorf("failed parsing empty value; got %#v", cfg.E)
+	}
+	if cfg.F != "fff" {
+		t.Errorf("failed setting default; expected %#v, got %#v", "fff", cfg.F)
+	}
+}
+
+func TestParseReaderSyntaxErrors(t *testing.T) {
+	type config struct {
+		A string `env:"A"`
+	}
+
+	for _, src := range []string{
+		"A",
+		"=xxx",
+		`A="unterminated`,
+		`A="xxx" yyy`,
+	} {
+		var cfg config
+		if err := ParseReader(strings.NewReader(src), &cfg); err == nil {
+			t.Errorf("expected a syntax error parsing %#v", src)
+		}
+	}
+}
+
+func TestParseFiles(t *testing.T) {
+	type config struct {
+		Host string `env:"FILES_HOST"`
+		Port int    `env:"FILES_PORT"`
+		Name string `env:"FILES_NAME"`
+	}
+
+	dir := t.TempDir()
+	base := filepath.Join(dir, ".env")
+	local := filepath.Join(dir, ".env.local")
+	missing := filepath.Join(dir, ".env.missing")
+
+	if err := os.WriteFile(base, []byte("FILES_HOST=localhost\nFILES_PORT=8000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(local, []byte("FILES_PORT=9000\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg config
+	if err := ParseFiles([]string{base, local, missing}, &cfg, WithLookuper(MapLookuper{"FILES_NAME": "Jane"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := config{Host: "localhost", Port: 9000}
+	if cfg != expected {
+		t.Errorf("failed parsing files; expected %#v, got %#v", expected, cfg)
+	}
+
+	cfg = config{}
+	if err := ParseFiles([]string{base, local}, &cfg, WithLookuper(MapLookuper{"FILES_NAME": "Jane", "FILES_PORT": "1"}), WithEnvFallback()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	expected.Name = "Jane"
+	if cfg != expected {
+		t.Errorf("failed falling back to the environment; expected %#v, got %#v", expected, cfg)
+	}
+
+	if err := ParseFiles([]string{base, missing}, &cfg, WithRequiredFiles()); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected an error for a missing required file; got %v", err)
+	}
+}
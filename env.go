@@ -20,11 +20,210 @@
 //
 //     `env:"NAME,required"`
 //
-// If a required flag is set the 'default' tag will be ignored.
+// If a required flag is set the 'default' tag will be ignored. Since that's
+// usually a mistake, WithStrictTags turns it into an error.
 //
-// Only a few types are supported: string, bool, int, []byte, *string, *bool,
-// *int, *[]byte. An error will be returned if other types are attempted to
-// be processed.
+// A variable can also be required only when another variable holds a certain
+// value, or when it's set at all if the value is omitted:
+//
+//     `env:"DATABASE_URL,required_if=ENV:production"`
+//     `env:"TLS_KEY,required_if=TLS_CERT"`
+//
+// Variables can also be placed in groups with the `group` tag. Groups named in
+// WithRequiredGroups are all or nothing: if any of their variables are set,
+// they all must be.
+//
+//     `env:"DB_USER" group:"db"`
+//
+// A description can be given in the `desc` tag, which is included in the error
+// returned when a required variable is missing.
+//
+//     `env:"NAME,required" desc:"the display name shown to users"`
+//
+// Variables that have been renamed can still be read from their old names,
+// which are listed in the `deprecated` tag. Use WithDeprecationHandler to be
+// notified when an old name is used.
+//
+//     `env:"NAME" deprecated:"USERNAME,USER_NAME"`
+//
+// With WithFlagOverrides, fields with a `flag` tag take their value from the
+// named flag when it was given on the command line, ahead of the environment.
+//
+//     `env:"PORT" flag:"port"`
+//
+// Bools can instead be set by the mere presence of their variable with the
+// `presence` tag, so DEBUG= sets the field to true and leaving DEBUG unset
+// sets it to false.
+//
+//     `env:"DEBUG" presence:"true"`
+//
+// A default of "-" means there's no default at all. To default to a literal
+// hyphen, or to anything else that would otherwise be treated specially, such
+// as a leading '@', escape it with a backslash:
+//
+//     `env:"SEPARATOR" default:"\\-"`
+//
+// Defaults can also be given in code with WithDefaults, which take precedence
+// over `default` tags.
+//
+// Defaults can refer to the values of fields that come before them in the
+// struct by their variable names, in braces. Referring to a field that hasn't
+// been parsed yet is an error.
+//
+//     `env:"CACHE_DIR" default:"{DATA_DIR}/cache"`
+//
+// Defaults beginning with an '@' are computed at runtime by a function
+// registered with WithDefaultFuncs.
+//
+//     `env:"DATA_DIR" default:"@dataDir"`
+//
+// Values can also be decoded from JSON, which is handy for structs, maps and
+// slices that would otherwise be awkward to express:
+//
+//     `env:"LIMITS" encoding:"json"`
+//
+// The values of []byte fields are taken literally, but they can be read from
+// a list of integers between 0 and 255 with `elements:"int"`.
+//
+//     `env:"BYTES" elements:"int"` // BYTES=10,20,30
+//
+// Values that arrive percent-encoded, such as passwords from some
+// orchestration tools, can be decoded with `encoding:"url"`.
+//
+//     `env:"DB_PASSWORD" encoding:"url"` // p%40ss becomes p@ss
+//
+// With `source:"file"` the variable holds the path of a file and the field is
+// set to the file's contents, which suits certificates and keys.
+//
+//     `env:"TLS_CERT_PATH" source:"file"` // []byte
+//
+// Hex strings can be decoded into []byte fields and fixed-size byte arrays,
+// such as keys, with `encoding:"hex"`, and base64 strings with
+// `encoding:"base64"`. The decoded length must match the length of an array.
+// Types implementing encoding.BinaryUnmarshaler are given the decoded bytes
+// to unmarshal.
+//
+//     `env:"SIGNING_KEY" encoding:"hex"` // [32]byte
+//     `env:"SESSION_KEY" encoding:"base64"`
+//
+// The length of a string, in runes, can be limited with the `minlen` and
+// `maxlen` tags. Values outside the bounds result in an ErrorOutOfRange.
+//
+//     `env:"USERNAME" minlen:"3" maxlen:"32"`
+//
+// A message for operators can be given with the `errmsg` tag. It's used in
+// place of the usual error when a required field is missing, its value can't
+// be parsed or its length is out of range.
+//
+//     `env:"LOG_LEVEL,required" errmsg:"Set LOG_LEVEL to one of debug/info/warn/error"`
+//
+// Fields holding sensitive values can be marked as secrets, in which case
+// their values will be redacted from errors.
+//
+//     `env:"API_KEY" secret:"true"`
+//
+// Slices of strings, bools, ints and floats are read from comma-separated
+// values. A different separator can be set with the `sep` tag, which also
+// accepts the names "newline", "tab" and "space".
+//
+//     `env:"WEIGHTS" sep:";"`
+//     `env:"HOSTS" sep:"newline"`
+//
+// Numbers can be written with grouping separators, which are removed before
+// parsing, by naming the separator in the `thousands` tag. This only applies
+// to single numbers, not lists.
+//
+//     `env:"MAX_ROWS" thousands:","` // MAX_ROWS=1,000,000
+//
+// Values can be normalized before they're parsed with the `transform` tag.
+// The transforms "lower", "upper" and "trim" are built in and others can be
+// registered with WithTransforms. Several transforms can be applied in order
+// by separating their names with commas.
+//
+//     `env:"HOSTNAME" transform:"trim,lower"`
+//
+// Whitespace around a value can also be trimmed with the "trim" option,
+// which, like "required", follows the name in the `env` tag. Options can be
+// combined in any order.
+//
+//     `env:"TOKEN,required,trim"`
+//
+// Other characters can be trimmed from around a value by listing them in the
+// `trimcutset` tag.
+//
+//     `env:"REGION" trimcutset:"\"'[]"`
+//
+// With WithAutoNames, fields without an `env` tag are read from variables
+// named after the field in upper snake case, such as MAX_WORKERS for
+// MaxWorkers.
+//
+// Untagged struct fields and pointers to structs are parsed recursively.
+// Pointers are allocated as needed, but are left nil if none of their fields
+// are given a value, which makes for neat optional groups of config.
+//
+//     type config struct {
+//         Database *DatabaseConfig
+//     }
+//
+// Numbers may use underscores between digits as separators, such as 10_000.
+//
+// The `unit` tag allows numbers to be given in units. With `unit:"bytes"`,
+// sizes such as 512MB or 1GiB are converted to a number of bytes, while with
+// `unit:"percent"` a value such as 50% becomes 50 for integers or 0.5 for
+// floats. Durations can be stored as numbers of ns, us, ms, s, m or h, so
+// with `unit:"ms"` both 500 and 0.5s become 500.
+//
+//     `env:"MEMORY_LIMIT" unit:"bytes"`
+//
+// With WithInterfaceInference, interface{} fields are set to an int, float64,
+// bool or string depending on what the value looks like, as are the values of
+// map[string]interface{} fields.
+//
+// Fixed-size arrays of the same types are read in the same way, but the number
+// of values must match the length of the array.
+//
+//     `env:"RGB"` // [3]int
+//
+// Maps with string keys and any of the above scalar types as values are read
+// from comma-separated key=value pairs, also honoring the `sep` tag.
+//
+//     `env:"LIMITS"` // LIMITS=a=1,b=2
+//
+// A map field whose variable name contains a *, such as ROUTE_*, collects
+// every variable matching the pattern, keyed by the part matched by the *.
+// This requires a Lookuper that implements Enumerator, as the process
+// environment does.
+//
+//     `env:"ROUTE_*"` // ROUTE_A=x ROUTE_B=y gives map[A:x B:y]
+//
+// Maps of strings to structs are read from a JSON object instead:
+//
+//     `env:"SERVERS"` // SERVERS={"a":{"host":"x"},"b":{"host":"y"}}
+//
+// and slices of structs from a JSON array:
+//
+//     `env:"SERVERS"` // SERVERS=[{"host":"a"},{"host":"b"}]
+//
+// Slices, maps and pointers to slices are left nil if their variable is unset
+// and there's no default. A variable that's set but empty results in an
+// empty, non-nil value. Likewise, the database/sql Null types are only marked
+// valid when their variable is set.
+//
+// Types implementing Unmarshaler are parsed with their UnmarshalEnv method,
+// and types implementing encoding.TextUnmarshaler, such as netip.Addr, with
+// their UnmarshalText method. Pointers to either are allocated when their
+// variable is set, and left nil otherwise.
+//
+// Parsers for other types can be registered with WithTypeParser.
+//
+// Only a few types are supported: string, bool, int, int64, float32, float64,
+// time.Duration, []byte, slices and arrays of the aforementioned scalar types,
+// slices of pointers to them, maps of strings to the aforementioned scalar
+// types, pointers to them or structs, *string, *bool, *int, *int64,
+// *time.Duration, *[]byte, pointers to slices of the aforementioned scalar
+// types, *big.Int, *big.Float, sql.NullString, sql.NullInt64, sql.NullBool,
+// sql.NullFloat64, and single uint and uint64 values. An error will be
+// returned if other types are attempted to be processed.
 //
 // Example:
 //
@@ -64,22 +263,175 @@
 package babyenv
 
 import (
+	"database/sql"
+	"encoding"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"math/big"
+	"net/url"
 	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// Replaces the values of secret fields in errors and other output
+const redacted = "****"
+
+// Precision, in bits, of *big.Float values
+const bigFloatPrec = 256
+
+var (
+	stringType      = reflect.TypeOf("")
+	durationType    = reflect.TypeOf(time.Duration(0))
+	durationPtrType = reflect.TypeOf((*time.Duration)(nil))
+	bigIntType      = reflect.TypeOf((*big.Int)(nil))
+	bigFloatType    = reflect.TypeOf((*big.Float)(nil))
+	nullStringType  = reflect.TypeOf(sql.NullString{})
+	nullInt64Type   = reflect.TypeOf(sql.NullInt64{})
+	nullBoolType    = reflect.TypeOf(sql.NullBool{})
+	nullFloat64Type = reflect.TypeOf(sql.NullFloat64{})
 )
 
+// Returned, wrapped in an ErrorInvalidValue, for negative unsigned values
+var errNegativeUnsigned = errors.New("negative values aren't allowed for unsigned fields")
+
 var (
 	// ErrorNotAStructPointer indicates that we were expecting a pointer to a
-	// struct but we didn't get it. This is returned when parsing a passed
-	// struct.
+	// struct but we didn't get it. The error returned when parsing a passed
+	// struct is an ErrorWrongKind, which matches this with errors.Is.
 	ErrorNotAStructPointer = errors.New("expected a pointer to a struct")
+
+	// ErrorMissingRequired is wrapped by every ErrorEnvVarRequired, so
+	// missing required variables can be detected with errors.Is without
+	// inspecting each error.
+	ErrorMissingRequired = errors.New("missing required environment variable")
 )
 
-// ErrorUnsettable is used when a field cannot be set
+// ErrorWrongKind is returned when we were expecting a pointer to a struct but
+// got something else. It records what we got, and matches
+// ErrorNotAStructPointer with errors.Is.
+type ErrorWrongKind struct {
+	// Kind of value we got, or of the value pointed to if Pointer is set.
+	// It's reflect.Invalid for nil.
+	Kind reflect.Kind
+
+	// Whether we got a pointer
+	Pointer bool
+}
+
+// Error implements the error interface
+func (e *ErrorWrongKind) Error() string {
+	var got string
+	switch {
+	case e.Pointer && e.Kind == reflect.Invalid:
+		got = "a nil pointer"
+	case e.Pointer:
+		got = "a pointer to " + withArticle(e.Kind.String())
+	case e.Kind == reflect.Invalid:
+		got = "nil"
+	default:
+		got = withArticle(e.Kind.String())
+	}
+	return fmt.Sprintf("%v, got %s", ErrorNotAStructPointer, got)
+}
+
+// Is reports whether target is ErrorNotAStructPointer, so callers checking for
+// the sentinel continue to work.
+func (e *ErrorWrongKind) Is(target error) bool {
+	return target == ErrorNotAStructPointer
+}
+
+// Prefix a word with "a" or "an"
+func withArticle(s string) string {
+	if s != "" && strings.ContainsRune("aeiou", rune(s[0])) {
+		return "an " + s
+	}
+	return "a " + s
+}
+
+// ErrorDuplicateName is used with WithUniqueNames when more than one field
+// reads the same environment variable. Fields holds the paths to the fields,
+// such as Primary.Host.
+type ErrorDuplicateName struct {
+	Name   string
+	Fields []string
+}
+
+// Error implements the error interface
+func (e *ErrorDuplicateName) Error() string {
+	return fmt.Sprintf("%s is used by more than one field: %s", e.Name, strings.Join(e.Fields, ", "))
+}
+
+// ErrorImmutable is used by Reload when the variable for a field tagged
+// `immutable:"true"` has changed since the field was first parsed
+type ErrorImmutable struct {
+	Name      string
+	FieldName string
+}
+
+// Error implements the error interface
+func (e *ErrorImmutable) Error() string {
+	return fmt.Sprintf("%s can't be changed by reloading (field %s)", e.Name, e.FieldName)
+}
+
+// ErrorOutOfRange is used when the length of a string, in runes, is outside
+// the bounds set by its field's `minlen` and `maxlen` tags. Either bound is
+// zero if its tag wasn't given. Message holds the contents of the field's
+// `errmsg` tag, if any, which replaces the usual message.
+type ErrorOutOfRange struct {
+	Name      string
+	FieldName string
+	Length    int
+	Min       int
+	Max       int
+	Message   string
+}
+
+// Error implements the error interface
+func (e *ErrorOutOfRange) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Length < e.Min {
+		return fmt.Sprintf("%s must be at least %d characters long; got %d", e.Name, e.Min, e.Length)
+	}
+	return fmt.Sprintf("%s must be at most %d characters long; got %d", e.Name, e.Max, e.Length)
+}
+
+// ErrorUntaggedField is used with WithStrictUnknown when a variable is set
+// that looks like it was meant for a field without an `env` tag
+type ErrorUntaggedField struct {
+	Name      string
+	FieldName string
+}
+
+// Error implements the error interface
+func (e *ErrorUntaggedField) Error() string {
+	return fmt.Sprintf("%s is set but field %s has no env tag", e.Name, e.FieldName)
+}
+
+// ErrorUnknownField is used by ParseFields when asked to parse a field the
+// struct doesn't have
+type ErrorUnknownField struct {
+	FieldName string
+}
+
+// Error implements the error interface
+func (e *ErrorUnknownField) Error() string {
+	return fmt.Sprintf("no field named %s", e.FieldName)
+}
+
+// ErrorUnsettable is used when a field cannot be set. FieldName holds the path
+// to the field, including any structs it's nested in.
 type ErrorUnsettable struct {
 	FieldName string
 }
@@ -92,246 +444,1703 @@ func (e *ErrorUnsettable) Error() string {
 // ErrorUnsupportedType is used when we attempt to parse a struct field of an
 // unsupported type
 type ErrorUnsupportedType struct {
-	Type reflect.Type
+	Type      reflect.Type
+	FieldName string
+	Name      string
 }
 
 // Error implements the error interface
 func (e *ErrorUnsupportedType) Error() string {
-	return fmt.Sprintf("unsupported type %v", e.Type)
+	if e.FieldName == "" {
+		return fmt.Sprintf("unsupported type %v", e.Type)
+	}
+	return fmt.Sprintf("unsupported type %v for field %s (env %s)", e.Type, e.FieldName, e.Name)
 }
 
 // ErrorEnvVarRequired is used when a `required` flag is used and the value of
-// the corresponding environment variable is empty
+// the corresponding environment variable is empty. Desc holds the contents of
+// the field's `desc` tag, if any, and Message the contents of its `errmsg`
+// tag, which replaces the usual message.
 type ErrorEnvVarRequired struct {
-	Name string
+	Name      string
+	FieldName string
+	Desc      string
+	Message   string
 }
 
 // Error implements the error interface
 func (e *ErrorEnvVarRequired) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Desc != "" {
+		return fmt.Sprintf("%s is required: %s", e.Name, e.Desc)
+	}
 	return fmt.Sprintf("%s is required", e.Name)
 }
 
-// Parse parses a struct for environment variables, placing found values in the
-// struct, altering it. We look at the 'env' tag for the environment variable
-// names, and the 'default' for the default value to the corresponding
-// environment variable.
-func Parse(cfg interface{}) error {
+// Unwrap returns ErrorMissingRequired
+func (e *ErrorEnvVarRequired) Unwrap() error {
+	return ErrorMissingRequired
+}
 
-	// Make sure we've got a pointer
-	val := reflect.ValueOf(cfg)
-	if val.Kind() != reflect.Ptr {
-		return ErrorNotAStructPointer
+// ErrorInvalidValue is used when the value of an environment variable (or its
+// default) can't be converted to the type of the corresponding field. If the
+// field is a secret the value is redacted and the underlying error, which may
+// also contain the value, is left out of the message. Err is then a stand-in
+// that doesn't mention the value at all, and can only be inspected with
+// errors.Is. FieldName holds the path to the field, such as
+// Database.Primary.Port, and Message the contents of the field's `errmsg`
+// tag, if any, which replaces the usual message.
+type ErrorInvalidValue struct {
+	Name      string
+	FieldName string
+	Value     string
+	Secret    bool
+	Err       error
+	Message   string
+}
+
+// Error implements the error interface
+func (e *ErrorInvalidValue) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	name := e.Name
+	if e.FieldName != "" {
+		name = fmt.Sprintf("%s (field %s)", e.Name, e.FieldName)
+	}
+	if e.Secret {
+		return fmt.Sprintf("invalid value %s for %s", redacted, name)
 	}
+	return fmt.Sprintf("invalid value %q for %s: %v", e.Value, name, e.Err)
+}
 
-	// Make sure our pointer points to a struct
-	ref := val.Elem()
-	if ref.Kind() != reflect.Struct {
-		return ErrorNotAStructPointer
+// Unwrap returns the underlying error
+func (e *ErrorInvalidValue) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList holds all of the errors encountered while parsing when errors are
+// being collected with WithCollectErrors
+type ErrorList []error
+
+// Error implements the error interface
+func (e ErrorList) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
 	}
+	return strings.Join(msgs, "; ")
+}
 
-	return parseFields(ref)
+// Unwrap returns the collected errors
+func (e ErrorList) Unwrap() []error {
+	return e
 }
 
-// Interate over the fields of a struct, looking for `env` tags indicating
-// environment variable names and `default` inicating default values. We're
-// expecting a pointer to a struct here, and either environment variables or
-// defaults will be placed in the struct. If a non-struct pointer is passed we
-// return an error.
+func newErrorInvalidValue(name, value string, secret bool, err error) *ErrorInvalidValue {
+	if secret {
+		err = &redactedError{err: err, name: name}
+		value = redacted
+	}
+	return &ErrorInvalidValue{Name: name, Value: value, Secret: secret, Err: err}
+}
+
+// redactedError stands in for the underlying error of a secret field's
+// ErrorInvalidValue, which may hold the value or part of it, such as in a
+// *strconv.NumError or an error about a single list element. Its message
+// never mentions the value, and since it can't be unwrapped the original
+// error can't be reached with errors.As. It can still be matched with
+// errors.Is.
+type redactedError struct {
+	err  error
+	name string
+}
+
+// Error implements the error interface
+func (e *redactedError) Error() string {
+	return fmt.Sprintf("invalid value %s for %s", redacted, e.name)
+}
+
+// Is reports whether the underlying error matches target
+func (e *redactedError) Is(target error) bool {
+	return errors.Is(e.err, target)
+}
+
+// Unmarshaler can be implemented by types that parse themselves from the
+// value of an environment variable. It takes precedence over
+// encoding.TextUnmarshaler. Pointers to Unmarshalers are left nil when their
+// variable is unset or empty, and are otherwise allocated before UnmarshalEnv
+// is called.
+type Unmarshaler interface {
+	UnmarshalEnv(value string) error
+}
+
+// AfterParser can be implemented by a config struct to validate or derive
+// values once all of its fields have been populated. AfterParse is called
+// once, on the struct passed to Parse, and any error it returns is returned by
+// Parse.
 //
-// Note that a required flag can also be passed in the form of:
+//     func (c *config) AfterParse() error {
+//         c.DSN = fmt.Sprintf("%s:%d", c.Host, c.Port)
+//         return nil
+//     }
+type AfterParser interface {
+	AfterParse() error
+}
+
+// Parse parses a struct for environment variables, placing found values in the
+// struct, altering it. We look at the 'env' tag for the environment variable
+// names, and the 'default' for the default value to the corresponding
+// environment variable.
 //
-//     VarName string `env:"VAR_NAME,required"`
+// If the struct implements AfterParser its AfterParse method is called after
+// parsing succeeds.
 //
-// If a required flag is set, and the environment variable is empty, the
-// `default` tag is ignored.
-func parseFields(ref reflect.Value) error {
-	for i := 0; i < ref.NumField(); i++ {
-		var (
-			field      = ref.Field(i)
-			fieldKind  = ref.Field(i).Kind()
-			fieldTags  = ref.Type().Field(i).Tag
-			fieldName  = ref.Type().Field(i).Name
-			envVarName string
-			required   bool
-		)
-
-		tagVal := fieldTags.Get("env")
-		if tagVal == "" || tagVal == "-" {
-			continue
-		}
-
-		if !field.CanSet() {
-			return &ErrorUnsettable{fieldName}
-		}
+// It's shorthand for New(opts...).Parse(cfg).
+func Parse(cfg interface{}, opts ...Option) error {
+	return New(opts...).Parse(cfg)
+}
 
-		// The tag we're looking at will look something like one of these:
-		//
-		//     `env:"NAME"`
-		//     `env:"NAME,required"`
-		//
-		// Here we split on the comma and sort out the parts.
-		tagValParts := strings.Split(tagVal, ",")
-		if len(tagValParts) == 0 { // This should never happen
-			continue
-		} else if len(tagValParts) >= 1 {
-			envVarName = tagValParts[0]
+// Parse a struct with the given options, calling AfterParse if the struct
+// implements AfterParser.
+func parse(cfg interface{}, o *options) error {
+	ref, err := structPointer(cfg)
+	if err != nil {
+		return err
+	}
+	o.setAutoPrefix(ref.Type())
+	o.useProcessEnv()
+	o.collectFlags()
+	if o.uniqueNames {
+		if err := duplicateNames(ref.Type(), o); err != nil {
+			return o.formatErrors(err)
 		}
-		if len(tagValParts) >= 2 && strings.TrimSpace(tagValParts[1]) == "required" {
-			required = true
+	}
+	if o.validateTypes {
+		if err := validateTypes(ref.Type(), o); err != nil {
+			return o.formatErrors(err)
 		}
+	}
 
-		// Get the value of the environment var
-		envVarVal := os.Getenv(envVarName)
-
-		// Return an error if the required flag is set and the env var is empty
-		if envVarVal == "" && required {
-			return &ErrorEnvVarRequired{envVarName}
+	// Catch misconfigured defaults before anything is set, regardless of
+	// whether they'd be used
+	if o.validateDefaults {
+		if err := o.validateDefaultsOnce(ref.Type()); err != nil {
+			return o.formatErrors(err)
 		}
+	}
 
-		defaultVal := fieldTags.Get("default")
+	if _, err = parseFields(ref, o); err != nil {
+		return o.formatErrors(err)
+	}
+	if err := o.checkGroups(); err != nil {
+		return o.formatErrors(err)
+	}
+	if p, ok := cfg.(AfterParser); ok {
+		return p.AfterParse()
+	}
+	return nil
+}
 
-		// Is the situation such that we should set a default value? We only
-		// do it if the value of the given environment varaiable is empty, and
-		// we have a non-empty default value.
-		shouldSetDefault := len(envVarVal) == 0 && len(defaultVal) > 0 && defaultVal != "-"
+// Validate reports the names of all required environment variables that are
+// unset (or empty) without populating the struct. Since the struct isn't
+// altered, either a struct or a pointer to one may be given.
+func Validate(cfg interface{}, opts ...Option) ([]string, error) {
+	ref, err := structValue(cfg)
+	if err != nil {
+		return nil, err
+	}
 
-		// Set the field accoring to it's kind
-		switch fieldKind {
+	o := newOptions(opts)
+	o.setAutoPrefix(ref.Type())
+	o.useProcessEnv()
+	return validateFields(ref.Type(), o)
+}
 
-		case reflect.String:
-			if shouldSetDefault {
-				field.SetString(defaultVal)
-				continue
-			}
-			field.SetString(envVarVal)
+// Collect the names of missing required variables for a struct type,
+// including those of nested structs.
+func validateFields(t reflect.Type, o *options) ([]string, error) {
+	if o.visiting[t] {
+		return nil, nil
+	}
+	o.visiting[t] = true
+	defer delete(o.visiting, t)
 
-		case reflect.Bool:
-			if shouldSetDefault {
-				if err := setBool(field, defaultVal); err != nil {
-					return err
-				}
-				continue
-			}
-			if err := setBool(field, envVarVal); err != nil {
-				return err
-			}
+	var missing []string
 
-		case reflect.Int:
-			if shouldSetDefault {
-				if err := setInt(field, defaultVal); err != nil {
-					return err
-				}
-				continue
-			}
-			if err := setInt(field, envVarVal); err != nil {
-				return err
+	for _, info := range cachedFields(t) {
+		if !o.hasEnvVar(info) {
+			nested := info.typ
+			if nested.Kind() == reflect.Ptr {
+				nested = nested.Elem()
 			}
-
-		case reflect.Int64:
-			if shouldSetDefault {
-				if err := setInt64(field, defaultVal); err != nil {
-					return err
-				}
+			if nested.Kind() != reflect.Struct || !info.exported {
 				continue
 			}
-			if err := setInt64(field, envVarVal); err != nil {
-				return err
+			leave := o.enterNested(info)
+			m, err := validateFields(nested, o)
+			leave()
+			if err != nil {
+				return nil, err
 			}
+			missing = append(missing, m...)
+			continue
+		}
 
-		// Slices are a whole can of worms
-		case reflect.Slice:
-			switch field.Type().Elem().Kind() {
-
-			// []uint8 is an alias for []byte
-			case reflect.Uint8:
-				if shouldSetDefault {
-					field.SetBytes([]byte(defaultVal))
-					continue
-				}
-				field.SetBytes([]byte(envVarVal))
+		required, err := o.isRequired(info.opts)
+		if err != nil {
+			return nil, err
+		}
+		if !required {
+			continue
+		}
 
-			default:
-				return &ErrorUnsupportedType{field.Type()}
+		envVarName := o.envName(info)
 
+		// Patterns such as ROUTE_* need at least one matching variable
+		if info.typ.Kind() == reflect.Map && isGlob(envVarName) {
+			matches, err := o.matchGlob(envVarName)
+			if err != nil {
+				return nil, err
 			}
+			if len(matches) == 0 {
+				missing = append(missing, envVarName)
+			}
+			continue
+		}
 
-		// Pointers are also a whole other can of worms
-		case reflect.Ptr:
-			ptr := field.Type().Elem()
+		val, found, err := o.lookupField(info.name, envVarName, info.tags)
+		if err != nil {
+			return nil, err
+		}
 
-			switch ptr.Kind() {
+		// Presence bools only need their variable to be set
+		if info.tags.Get("presence") == "true" && info.typ.Kind() == reflect.Bool {
+			if !found {
+				missing = append(missing, envVarName)
+			}
+			continue
+		}
+		if val == "" {
+			missing = append(missing, envVarName)
+		}
+	}
 
-			case reflect.String:
-				if shouldSetDefault {
-					field.Set(reflect.ValueOf(&defaultVal))
-					continue
-				}
-				field.Set(reflect.ValueOf(&envVarVal))
-
-			case reflect.Bool:
-				if shouldSetDefault {
-					if err := setBoolPointer(field, defaultVal); err != nil {
-						return err
-					}
-					continue
-				}
-				if err := setBoolPointer(field, envVarVal); err != nil {
-					return err
-				}
+	return missing, nil
+}
 
-			case reflect.Int:
-				if shouldSetDefault {
-					if err := setIntPointer(field, defaultVal); err != nil {
-						return err
-					}
-					continue
-				}
-				if err := setIntPointer(field, envVarVal); err != nil {
-					return err
-				}
+// Get the struct a pointer points to, returning an error if we didn't get a
+// pointer to a struct.
+func structPointer(cfg interface{}) (reflect.Value, error) {
 
-			case reflect.Int64:
-				if shouldSetDefault {
-					if err := setInt64Pointer(field, defaultVal); err != nil {
-						return err
-					}
-					continue
-				}
-				if err := setInt64Pointer(field, envVarVal); err != nil {
-					return err
-				}
+	// Make sure we've got a pointer
+	val := reflect.ValueOf(cfg)
+	if val.Kind() != reflect.Ptr {
+		return reflect.Value{}, &ErrorWrongKind{Kind: val.Kind()}
+	}
 
-			// A poiner to a slice!! Whole other level
-			case reflect.Slice:
+	// Make sure our pointer points to a struct
+	ref := val.Elem()
+	if ref.Kind() != reflect.Struct {
+		return reflect.Value{}, &ErrorWrongKind{Kind: ref.Kind(), Pointer: true}
+	}
 
-				switch ptr.Elem().Kind() {
+	return ref, nil
+}
 
-				// *[]uint8 is an alias for *[]byte
-				case reflect.Uint8:
-					var byteSlice []byte
-					if shouldSetDefault {
-						byteSlice = []byte(defaultVal)
-					} else {
-						byteSlice = []byte(envVarVal)
-					}
-					field.Set(reflect.ValueOf(&byteSlice))
+// ValidateStruct checks that the types of all of a struct's fields are
+// supported, without reading the environment or altering the struct. If any
+// aren't, an ErrorList of ErrorUnsupportedType is returned listing every one.
+// With WithUniqueNames, an ErrorDuplicateName is included ahead of those for
+// each variable read by more than one field. Either a struct or a pointer to
+// one may be given.
+func ValidateStruct(cfg interface{}, opts ...Option) error {
+	ref, err := structValue(cfg)
+	if err != nil {
+		return err
+	}
+
+	o := newOptions(opts)
+	o.setAutoPrefix(ref.Type())
+
+	var errs ErrorList
+	if o.uniqueNames {
+		if err := duplicateNames(ref.Type(), o); err != nil {
+			errs = append(errs, err.(ErrorList)...)
+		}
+	}
+	if err := validateTypes(ref.Type(), o); err != nil {
+		errs = append(errs, err.(ErrorList)...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Check that no two fields of a struct, including those of nested structs,
+// read the same variable. An ErrorList of ErrorDuplicateName is returned if
+// any do.
+func duplicateNames(t reflect.Type, o *options) error {
+	var (
+		names  []string
+		fields = make(map[string][]string)
+	)
+	for _, f := range describeFields(t, o) {
+		if _, ok := fields[f.Name]; !ok {
+			names = append(names, f.Name)
+		}
+		fields[f.Name] = append(fields[f.Name], f.Field)
+	}
+
+	var errs ErrorList
+	for _, name := range names {
+		if len(fields[name]) > 1 {
+			errs = append(errs, &ErrorDuplicateName{Name: name, Fields: fields[name]})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Check the types of a struct's fields, including those of nested structs, by
+// parsing an empty value into a throwaway value of each type.
+func validateTypes(t reflect.Type, o *options) error {
+	if o.visiting[t] {
+		return nil
+	}
+	o.visiting[t] = true
+	defer delete(o.visiting, t)
+
+	var errs ErrorList
+
+	for _, info := range cachedFields(t) {
+		if !o.hasEnvVar(info) {
+			nested := info.typ
+			if nested.Kind() == reflect.Ptr {
+				nested = nested.Elem()
+			}
+			if nested.Kind() != reflect.Struct || !info.exported {
+				continue
+			}
+			leave := o.enterNested(info)
+			err := validateTypes(nested, o)
+			leave()
+			if err != nil {
+				errs = append(errs, err.(ErrorList)...)
+			}
+			continue
+		}
+
+		tmp := reflect.New(info.typ).Elem()
+		var unsupported *ErrorUnsupportedType
+		if err := assignValue(tmp, "", info, o); errors.As(err, &unsupported) {
+			errs = append(errs, unsupported)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Get a struct for read-only use, dereferencing it if we got a pointer to
+// one. An error is returned if we didn't get a struct.
+func structValue(cfg interface{}) (reflect.Value, error) {
+	val := reflect.ValueOf(cfg)
+	isPtr := val.Kind() == reflect.Ptr
+	if isPtr {
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return reflect.Value{}, &ErrorWrongKind{Kind: val.Kind(), Pointer: isPtr}
+	}
+	return val, nil
+}
+
+// ParseLenient parses a struct like Parse, but carries on past values that
+// can't be parsed, giving those fields their default, or their zero value if
+// there isn't one. Missing required variables don't stop parsing either. All
+// of the problems encountered are returned, or nil if there weren't any.
+func ParseLenient(cfg interface{}, opts ...Option) []error {
+	o := newOptions(opts)
+	o.collectErrors = true
+	o.lenient = true
+	o.errorFormatter = nil
+
+	err := parse(cfg, o)
+	if err == nil {
+		return nil
+	}
+	if list, ok := err.(ErrorList); ok {
+		return list
+	}
+	return []error{err}
+}
+
+// Reload parses a struct that has already been populated again, such as when
+// reloading config on SIGHUP. Fields whose variables have changed are
+// updated, and those whose variables are no longer set go back to their
+// defaults, or to what they would be if the struct were parsed from scratch.
+// Fields without variables are left alone. It's safe to call any number of
+// times, but if an error is returned the struct may have been partly updated.
+//
+// Fields tagged `immutable:"true"`, such as a port to bind to, can't be changed
+// by reloading. If the value of one of their variables no longer matches the
+// value the field holds, an ErrorImmutable is returned and the field is left
+// as it is.
+//
+// Reload ignores WithRespectExistingValues, since every value would be an
+// existing one.
+func Reload(cfg interface{}, opts ...Option) error {
+	o := newOptions(opts)
+	o.respectExisting = false
+	o.reloading = true
+	return parse(cfg, o)
+}
+
+// ParseFields parses only the named fields of a struct, leaving the others
+// untouched, which is handy for reloading part of a config:
+//
+//     err := babyenv.ParseFields(&cfg, []string{"Features"})
+//
+// Fields are named as they are in Go, not by their environment variables. An
+// ErrorUnknownField is returned, before anything is parsed, if a name doesn't
+// match a field. Since the struct is only partly parsed, AfterParse isn't
+// called and required groups aren't checked.
+func ParseFields(cfg interface{}, fieldNames []string, opts ...Option) error {
+	ref, err := structPointer(cfg)
+	if err != nil {
+		return err
+	}
+
+	all := cachedFields(ref.Type())
+	fields := make([]*fieldInfo, 0, len(fieldNames))
+	for _, name := range fieldNames {
+		info := fieldNamed(all, name)
+		if info == nil {
+			return &ErrorUnknownField{FieldName: name}
+		}
+		fields = append(fields, info)
+	}
+
+	o := newOptions(opts)
+	o.setAutoPrefix(ref.Type())
+	o.useProcessEnv()
+	o.collectFlags()
+	if o.validateDefaults {
+		if err := o.validateDefaultsOnce(ref.Type()); err != nil {
+			return o.formatErrors(err)
+		}
+	}
+	if _, err = parseFieldList(ref, fields, o); err != nil {
+		return o.formatErrors(err)
+	}
+	return nil
+}
+
+func fieldNamed(fields []*fieldInfo, name string) *fieldInfo {
+	for _, info := range fields {
+		if info.name == name {
+			return info
+		}
+	}
+	return nil
+}
+
+// ParseMultiple parses several structs at once. Options can be passed
+// alongside the structs and apply to all of them:
+//
+//     err := babyenv.ParseMultiple(&server, &db, &cache, babyenv.WithCollectErrors())
+//
+// By default parsing stops at the first error. If WithCollectErrors is given,
+// every struct is parsed and an ErrorList is returned containing all of the
+// errors encountered. A formatter set with WithErrorFormatter is given the
+// errors from every struct at once.
+func ParseMultiple(cfgs ...interface{}) error {
+	var opts []Option
+	var structs []interface{}
+	for _, cfg := range cfgs {
+		if opt, ok := cfg.(Option); ok {
+			opts = append(opts, opt)
+			continue
+		}
+		structs = append(structs, cfg)
+	}
+
+	o := newOptions(opts)
+
+	// Errors are formatted once they've all been collected, rather than
+	// struct by struct
+	each := o.clone()
+	each.errorFormatter = nil
+
+	var errs ErrorList
+	for _, cfg := range structs {
+		if err := parse(cfg, each.clone()); err != nil {
+			if !o.collectErrors {
+				return o.formatErrors(err)
+			}
+			if list, ok := err.(ErrorList); ok {
+				errs = append(errs, list...)
+				continue
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return o.formatErrors(errs)
+	}
+	return nil
+}
+
+// Interate over the fields of a struct, looking for `env` tags indicating
+// environment variable names and `default` inicating default values. We're
+// expecting a pointer to a struct here, and either environment variables or
+// defaults will be placed in the struct. If a non-struct pointer is passed we
+// return an error.
+//
+// Note that a required flag can also be passed in the form of:
+//
+//     VarName string `env:"VAR_NAME,required"`
+//
+// If a required flag is set, and the environment variable is empty, the
+// `default` tag is ignored.
+//
+// Untagged struct fields, and pointers to structs, are recursed into. We
+// report whether any field was given a value from the environment or a
+// default.
+func parseFields(ref reflect.Value, o *options) (bool, error) {
+	return parseFieldList(ref, cachedFields(ref.Type()), o)
+}
+
+// Parse the given fields of a struct, stopping at the first error unless
+// errors are being collected.
+func parseFieldList(ref reflect.Value, fields []*fieldInfo, o *options) (bool, error) {
+	var (
+		errs   ErrorList
+		anySet bool
+	)
+
+	for _, info := range fields {
+		set, err := parseField(ref.Field(info.index), info, o)
+		if err != nil {
+			if !o.collectErrors {
+				return false, err
+			}
+			if list, ok := err.(ErrorList); ok {
+				errs = append(errs, list...)
+				continue
+			}
+			errs = append(errs, err)
+		}
+		anySet = anySet || set
+	}
+
+	if len(errs) > 0 {
+		return anySet, errs
+	}
+	return anySet, nil
+}
+
+// Make sure a string field's length, in runes, is within the bounds given by
+// its `minlen` and `maxlen` tags, if it has them.
+func (o *options) checkLength(field reflect.Value, info *fieldInfo) error {
+	minTag, maxTag := info.tags.Get("minlen"), info.tags.Get("maxlen")
+	if minTag == "" && maxTag == "" {
+		return nil
+	}
+
+	if field.Kind() == reflect.Ptr && !field.IsNil() {
+		field = field.Elem()
+	}
+	if field.Kind() != reflect.String {
+		return fmt.Errorf("minlen and maxlen only apply to strings, not field %s", o.fieldPath(info))
+	}
+
+	var bounds [2]int
+	for i, tag := range []string{minTag, maxTag} {
+		if tag == "" {
+			continue
+		}
+		n, err := strconv.Atoi(tag)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid length %q in tag of field %s", tag, o.fieldPath(info))
+		}
+		bounds[i] = n
+	}
+
+	n := utf8.RuneCountInString(field.String())
+	if (minTag != "" && n < bounds[0]) || (maxTag != "" && n > bounds[1]) {
+		return &ErrorOutOfRange{
+			Name:      o.envName(info),
+			FieldName: o.fieldPath(info),
+			Length:    n,
+			Min:       bounds[0],
+			Max:       bounds[1],
+			Message:   info.tags.Get("errmsg"),
+		}
+	}
+	return nil
+}
+
+// Parse an immutable field into a throwaway value while reloading, and make
+// sure it's the value the field already holds.
+func (o *options) reloadImmutable(field reflect.Value, info *fieldInfo) (bool, error) {
+	tmp := reflect.New(field.Type()).Elem()
+
+	o.reloading = false
+	set, err := parseField(tmp, info, o)
+	o.reloading = true
+	if err != nil {
+		return false, err
+	}
+
+	if !reflect.DeepEqual(tmp.Interface(), field.Interface()) {
+		return false, &ErrorImmutable{Name: o.envName(info), FieldName: o.fieldPath(info)}
+	}
+	return set, nil
+}
+
+// Parse a single struct field, looking up its environment variable and
+// falling back to its default. We report whether the field was given a value.
+func parseField(field reflect.Value, info *fieldInfo, o *options) (bool, error) {
+	var (
+		fieldTags  = info.tags
+		fieldName  = o.fieldPath(info)
+		envVarName = o.envName(info)
+		tagOpts    = info.opts
+	)
+
+	if !o.hasEnvVar(info) && !info.nested && o.strictUnknown && info.exported {
+		if _, found, err := o.lookup(envVarName); err != nil {
+			return false, err
+		} else if found {
+			return false, &ErrorUntaggedField{Name: envVarName, FieldName: fieldName}
+		}
+	}
+
+	if !o.hasEnvVar(info) {
+		leave := o.enterNested(info)
+		set, err := parseNested(field, o)
+		leave()
+		return set, err
+	}
+
+	if info.tagErr != nil {
+		return false, fmt.Errorf("invalid tag on field %s: %w", fieldName, info.tagErr)
+	}
+
+	if !field.CanSet() {
+		if !o.allowUnexported || !field.CanAddr() {
+			return false, &ErrorUnsettable{fieldName}
+		}
+
+		// Sidestep the restriction on setting unexported fields
+		field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+	}
+
+	// Map fields named with a pattern, such as ROUTE_*, collect every
+	// matching variable
+	if field.Kind() == reflect.Map && isGlob(envVarName) {
+		return o.parseGlob(field, info, envVarName)
+	}
+
+	// Immutable fields keep the value they were first given when reloading
+	if o.reloading && info.immutable {
+		return o.reloadImmutable(field, info)
+	}
+
+	// A required field's default would never be used
+	if o.strictTags && tagOpts.required && info.defaultVal != "" {
+		return false, fmt.Errorf("field %s is required but has a default, which would be ignored", fieldName)
+	}
+
+	// Get the value of the environment var
+	envVarVal, found, err := o.lookupField(fieldName, envVarName, fieldTags)
+	if err != nil {
+		return false, err
+	}
+
+	// Flags that were set on the command line take precedence
+	fromFlag := false
+	if v, ok := o.flagValue(fieldTags.Get("flag")); ok {
+		envVarVal, found, fromFlag = v, true, true
+	}
+
+	// The unset token counts as the variable not being set at all
+	unset := found && o.unsetToken != "" && envVarVal == o.unsetToken
+	if unset {
+		envVarVal, found = "", false
+	}
+
+	o.noteGroup(info, envVarVal != "")
+
+	// Fields tagged `presence:"true"` are true whenever the variable is set,
+	// whatever its value, even an empty one
+	presence := fieldTags.Get("presence") == "true" && field.Kind() == reflect.Bool
+
+	// Return an error if the required flag is set and the env var is empty,
+	// or, for presence fields, unset
+	required, err := o.isRequired(tagOpts)
+	if err != nil {
+		return false, err
+	}
+	if required && ((presence && !found) || (!presence && envVarVal == "")) {
+		return false, &ErrorEnvVarRequired{Name: envVarName, FieldName: fieldName, Desc: fieldTags.Get("desc"), Message: fieldTags.Get("errmsg")}
+	}
+
+	// The unset token clears a field, whatever its default
+	if unset {
+		field.Set(reflect.Zero(field.Type()))
+		o.record(info, "", sourceEnv)
+		return false, nil
+	}
+
+	if presence {
+		field.SetBool(found)
+		if found {
+			o.record(info, envVarVal, sourceEnv)
+		} else {
+			o.record(info, "", sourceZero)
+		}
+		return found, nil
+	}
+
+	defaultVal := o.defaultFor(info)
+
+	// Is the situation such that we should set a default value? We only
+	// do it if the value of the given environment varaiable is empty, and
+	// we have a non-empty default value.
+	shouldSetDefault := len(envVarVal) == 0 && len(defaultVal) > 0 && defaultVal != "-"
+	set := found || shouldSetDefault
+
+	// Keep track of where the value came from
+	src := sourceZero
+	if shouldSetDefault {
+		src = sourceDefault
+	} else if fromFlag {
+		src = sourceFlag
+	} else if found {
+		src = sourceEnv
+	}
+
+	// Leave values that were set before parsing alone if we've been asked to
+	// and there's nothing to replace them with
+	if o.respectExisting && !found && !shouldSetDefault && !field.IsZero() {
+		o.record(info, fmt.Sprint(field.Interface()), sourceExisting)
+		return false, nil
+	}
+
+	val := envVarVal
+	if shouldSetDefault {
+		if val, err = o.resolveDefault(info); err != nil {
+			return false, err
+		}
+	} else if found && o.expandValues {
+		expanded, err := o.expand(val)
+		if err != nil {
+			return false, o.invalidValue(info, envVarName, val, fmt.Errorf("could not expand value: %w", err))
+		}
+		val = expanded
+	}
+
+	if tagOpts.trim {
+		val = strings.TrimSpace(val)
+	}
+	if cutset := fieldTags.Get("trimcutset"); cutset != "" {
+		val = strings.Trim(val, cutset)
+	}
+
+	// Run the value through any transforms named in the `transform` tag
+	if transforms := fieldTags.Get("transform"); transforms != "" {
+		if val, err = o.transform(val, transforms); err != nil {
+			return false, fmt.Errorf("could not transform field %s: %w", fieldName, err)
+		}
+	}
+
+	// Pointers are left nil when the value is one of the null tokens
+	if field.Kind() == reflect.Ptr && o.nullTokens[val] {
+		field.Set(reflect.Zero(field.Type()))
+		o.record(info, val, src)
+		return set, nil
+	}
+
+	if err := assignValue(field, val, info, o); err != nil {
+		var unsupported *ErrorUnsupportedType
+		if o.skipUnsupported && errors.As(err, &unsupported) {
+			o.record(info, "", sourceSkipped)
+			return false, nil
+		}
+		var invalid *ErrorInvalidValue
+		if errors.As(err, &invalid) {
+			o.describeInvalid(invalid, info)
+			if o.lenient {
+				o.fallBack(field, info, src)
+			}
+		}
+		return false, err
+	}
+
+	// Slices, maps and pointers to slices are left nil, and sql.Null* types
+	// invalid, when the variable isn't set at all. A variable that's set but
+	// empty results in an empty, non-nil value.
+	if !set && zeroWhenUnset(field.Type()) {
+		field.Set(reflect.Zero(field.Type()))
+	}
+
+	if set {
+		if err := o.checkLength(field, info); err != nil {
+			return false, err
+		}
+	}
+
+	o.record(info, val, src)
+	return set, nil
+}
+
+// Build an ErrorInvalidValue for a value of a field that couldn't be parsed.
+// The name is that of the variable the value came from.
+func (o *options) invalidValue(info *fieldInfo, name, val string, err error) *ErrorInvalidValue {
+	invalid := newErrorInvalidValue(name, val, info.secret, err)
+	o.describeInvalid(invalid, info)
+	return invalid
+}
+
+// Fill in the path to the field an ErrorInvalidValue concerns and the message
+// from its `errmsg` tag.
+func (o *options) describeInvalid(invalid *ErrorInvalidValue, info *fieldInfo) {
+	invalid.FieldName = o.fieldPath(info)
+	invalid.Message = info.tags.Get("errmsg")
+}
+
+// Get a field's default, preferring one given with WithDefaults over the
+// `default` tag.
+func (o *options) defaultFor(info *fieldInfo) string {
+	if v, ok := o.defaults[o.envName(info)]; ok {
+		return v
+	}
+	return info.defaultVal
+}
+
+// Work out the value of a field's default. Defaults in the form
+// `default:"@name"` are computed at runtime by a function registered with
+// WithDefaultFuncs, while others can refer to earlier fields and, with
+// WithExpandDefaults, to other variables.
+func (o *options) resolveDefault(info *fieldInfo) (string, error) {
+	val := o.defaultFor(info)
+
+	// A leading backslash makes the rest of the default literal, so
+	// `default:"\\-"` is a hyphen rather than no default at all
+	if strings.HasPrefix(val, `\`) {
+		return val[1:], nil
+	}
+
+	if strings.HasPrefix(val, "@") {
+		v, err := o.computeDefault(val[1:])
+		if err != nil {
+			return "", fmt.Errorf("could not compute default for field %s: %w", o.fieldPath(info), err)
+		}
+		return v, nil
+	}
+
+	// Defaults can refer to the values of fields parsed earlier, such as
+	// `default:"{DATA_DIR}/cache"`
+	val, err := o.substituteFields(val)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve default for field %s: %w", o.fieldPath(info), err)
+	}
+	if o.expandDefaults {
+		if val, err = o.expand(val); err != nil {
+			return "", fmt.Errorf("could not expand default for field %s: %w", o.fieldPath(info), err)
+		}
+	}
+	return val, nil
+}
+
+// When a value couldn't be parsed in lenient mode, give the field its default
+// instead, if it has one that wasn't the value at fault. Otherwise the field
+// is left with its zero value. Problems with the default are ignored, since
+// the original error is reported.
+func (o *options) fallBack(field reflect.Value, info *fieldInfo, src source) {
+	field.Set(reflect.Zero(field.Type()))
+
+	if defaultVal := o.defaultFor(info); src != sourceDefault && defaultVal != "" && defaultVal != "-" {
+		val, err := o.resolveDefault(info)
+		if err == nil {
+			if transforms := info.tags.Get("transform"); transforms != "" {
+				val, err = o.transform(val, transforms)
+			}
+		}
+		if err == nil && assignValue(field, val, info, o) == nil {
+			o.record(info, val, sourceDefault)
+			return
+		}
+		field.Set(reflect.Zero(field.Type()))
+	}
+
+	o.record(info, "", sourceZero)
+}
+
+// Check the defaults of a struct's fields, including those of nested structs.
+// Unless errors are being collected, only the first problem is returned.
+func validateDefaults(t reflect.Type, o *options) error {
+	errs := defaultErrors(t, o)
+	switch {
+	case len(errs) == 0:
+		return nil
+	case !o.collectErrors:
+		return errs[0]
+	}
+	return errs
+}
+
+func defaultErrors(t reflect.Type, o *options) ErrorList {
+	if o.visiting[t] {
+		return nil
+	}
+	o.visiting[t] = true
+	defer delete(o.visiting, t)
+
+	var errs ErrorList
+
+	for _, info := range cachedFields(t) {
+		if !o.hasEnvVar(info) {
+			nested := info.typ
+			if nested.Kind() == reflect.Ptr {
+				nested = nested.Elem()
+			}
+			if nested.Kind() != reflect.Struct || !info.exported {
+				continue
+			}
+			leave := o.enterNested(info)
+			errs = append(errs, defaultErrors(nested, o)...)
+			leave()
+			continue
+		}
+
+		if err := validateDefault(reflect.New(info.typ).Elem(), info, o); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// Make sure a field's default can be parsed by parsing it into a throwaway
+// value. Computed defaults are skipped, as are those referring to other
+// fields or, with WithExpandDefaults, to variables, since their values aren't
+// known until parsing.
+func validateDefault(field reflect.Value, info *fieldInfo, o *options) error {
+	defaultVal := o.defaultFor(info)
+	if defaultVal == "" || defaultVal == "-" || strings.HasPrefix(defaultVal, "@") {
+		return nil
+	}
+	if strings.HasPrefix(defaultVal, `\`) {
+		defaultVal = defaultVal[1:]
+	} else if hasFieldReference(defaultVal) || (o.expandDefaults && strings.Contains(defaultVal, "$")) {
+		return nil
+	}
+
+	if transforms := info.tags.Get("transform"); transforms != "" {
+		var err error
+		if defaultVal, err = o.transform(defaultVal, transforms); err != nil {
+			return fmt.Errorf("could not transform field %s: %w", o.fieldPath(info), err)
+		}
+	}
+
+	tmp := reflect.New(field.Type()).Elem()
+	if err := assignValue(tmp, defaultVal, info, o); err != nil {
+		return fmt.Errorf("invalid default for field %s: %w", o.fieldPath(info), err)
+	}
+	return nil
+}
+
+// Convert a value and place it in a field.
+func assignValue(field reflect.Value, val string, info *fieldInfo, o *options) error {
+
+	// Fields tagged with `source:"file"` hold the path of a file, and are
+	// set to its contents
+	if info.tags.Get("source") == "file" && val != "" {
+		b, err := os.ReadFile(val)
+		if err != nil {
+			return fmt.Errorf("could not read %s from %s: %w", o.envName(info), val, err)
+		}
+		val = string(b)
+	}
+
+	switch info.tags.Get("encoding") {
+
+	// Fields tagged with `encoding:"json"` are unmarshalled wholesale,
+	// which allows for arbitrary structs, maps and slices. We decode into a
+	// fresh value so nothing from a previous parse is merged in.
+	case "json":
+		if val == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		ptr := reflect.New(field.Type())
+		if err := json.Unmarshal([]byte(val), ptr.Interface()); err != nil {
+			return newErrorInvalidValue(o.envName(info), val, info.secret, fmt.Errorf("could not parse JSON: %w", err))
+		}
+		field.Set(ptr.Elem())
+		return nil
+
+	// Fields tagged with `encoding:"hex"` are decoded into bytes
+	case "hex":
+		if val == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		if err := setHex(field, val); err != nil {
+			return newErrorInvalidValue(o.envName(info), val, info.secret, err)
+		}
+		return nil
+
+	// Fields tagged with `encoding:"base64"` are decoded into bytes too
+	case "base64":
+		if val == "" {
+			field.Set(reflect.Zero(field.Type()))
+			return nil
+		}
+		if err := setBase64(field, val); err != nil {
+			return newErrorInvalidValue(o.envName(info), val, info.secret, err)
+		}
+		return nil
+
+	// Fields tagged with `encoding:"url"` are percent-decoded, and then set
+	// as usual
+	case "url":
+		decoded, err := url.QueryUnescape(val)
+		if err != nil {
+			return newErrorInvalidValue(o.envName(info), val, info.secret, err)
+		}
+		val = decoded
+	}
+
+	if o.unquote && isStringish(field.Type()) {
+		unquoted, err := unquote(val)
+		if err != nil {
+			return newErrorInvalidValue(o.envName(info), val, info.secret, err)
+		}
+		val = unquoted
+	}
+
+	// Numbers can be written with grouping separators, like 1,000,000,
+	// when there's a `thousands` tag
+	if sep := info.tags.Get("thousands"); sep != "" && isNumeric(field.Type()) {
+		v, err := stripThousands(val, sep)
+		if err != nil {
+			return newErrorInvalidValue(o.envName(info), val, info.secret, err)
+		}
+		val = v
+	}
+
+	// Values given in units, like 512MB, are converted to plain numbers
+	if unit := info.tags.Get("unit"); unit != "" {
+		v, err := convertUnit(val, unit, field.Type())
+		if err != nil {
+			return newErrorInvalidValue(o.envName(info), val, info.secret, err)
+		}
+		val = v
+	}
+
+	if err := setValue(field, val, info.tags, o); err != nil {
+		var unsupported *ErrorUnsupportedType
+		if errors.As(err, &unsupported) {
+			unsupported.FieldName = o.fieldPath(info)
+			unsupported.Name = o.envName(info)
+			return unsupported
+		}
+		return newErrorInvalidValue(o.envName(info), val, info.secret, err)
+	}
+
+	return nil
+}
+
+// Recurse into an untagged struct field or pointer to a struct. Nil pointers
+// are allocated, but left nil if none of the nested fields were given a value
+// unless WithAllocateNilStructs has been set.
+func parseNested(field reflect.Value, o *options) (bool, error) {
+	if !field.CanSet() {
+		return false, nil
+	}
+
+	switch {
+	case field.Kind() == reflect.Struct:
+		return parseFields(field, o)
+
+	case field.Kind() == reflect.Ptr && field.Type().Elem().Kind() == reflect.Struct:
+		if !field.IsNil() && o.reloading {
+			return reloadNested(field, o)
+		}
+		if !field.IsNil() {
+			return parseFields(field.Elem(), o)
+		}
+
+		// Don't allocate our way down a self-referential type forever
+		t := field.Type().Elem()
+		if o.visiting[t] {
+			return false, nil
+		}
+		o.visiting[t] = true
+		defer delete(o.visiting, t)
+
+		ptr := reflect.New(t)
+		set, err := parseFields(ptr.Elem(), o)
+		if set || o.allocateNilStructs {
+			field.Set(ptr)
+		}
+		return set, err
+	}
+
+	return false, nil
+}
+
+// Reload an allocated pointer to a struct. It's parsed as a copy first, so
+// that, as with a fresh Parse, it can go back to nil if none of its fields
+// have a value any more.
+func reloadNested(field reflect.Value, o *options) (bool, error) {
+	cp := reflect.New(field.Type().Elem())
+	cp.Elem().Set(field.Elem())
+
+	set, err := parseFields(cp.Elem(), o)
+	if !set && !o.allocateNilStructs {
+		field.Set(reflect.Zero(field.Type()))
+		return set, err
+	}
+	field.Elem().Set(cp.Elem())
+	return set, err
+}
+
+// Look up the environment variable for a field. If the var isn't set, try any
+// deprecated names listed in the `deprecated` tag, in order.
+func (o *options) lookupField(fieldName, envVarName string, tags reflect.StructTag) (string, bool, error) {
+	val, found, err := o.lookup(envVarName)
+	if err != nil || found {
+		return val, found, err
+	}
+
+	deprecated := tags.Get("deprecated")
+	if deprecated == "" {
+		return val, found, nil
+	}
+
+	for _, oldName := range strings.Split(deprecated, ",") {
+		oldName = o.composeName(strings.TrimSpace(oldName))
+		if val, found, err = o.lookup(oldName); err != nil {
+			return "", false, err
+		}
+		if found {
+			if o.deprecationHandler != nil {
+				o.deprecationHandler(fieldName, oldName, envVarName)
+			}
+			if o.logger != nil {
+				o.logger.Warn("deprecated environment variable used",
+					slog.String("field", fieldName),
+					slog.String("name", oldName),
+					slog.String("replacement", envVarName),
+				)
+			}
+			break
+		}
+	}
+
+	return val, found, nil
+}
+
+// envTagOptions holds the options that can follow the name in an `env` tag
+type envTagOptions struct {
+	required   bool
+	requiredIf *condition
+	trim       bool
+}
+
+// condition holds the environment variable and value referenced by a
+// `required_if` option. If value is empty the condition is met when the
+// variable has any value at all.
+type condition struct {
+	name  string
+	value string
+}
+
+// Work out whether a field is required, checking the environment for any
+// `required_if` condition.
+func (o *options) isRequired(opts envTagOptions) (bool, error) {
+	if opts.required || opts.requiredIf == nil {
+		return opts.required, nil
+	}
+
+	val, _, err := o.lookup(opts.requiredIf.name)
+	if err != nil {
+		return false, err
+	}
+	if opts.requiredIf.value == "" {
+		return val != "", nil
+	}
+	return val == opts.requiredIf.value, nil
+}
+
+// Split an `env` tag into the variable name and its options. Options are only
+// recognized at the end of the tag, so anything before them, commas and all,
+// is treated as the name. Several options can be given in any order:
+//
+//     `env:"NAME,required,trim"`
+//
+// An unrecognized token following an option, such as the typo in
+// `env:"NAME,required,tirm"`, is an error rather than part of the name, so
+// that it can't quietly drop the options before it.
+func parseEnvTag(tag string) (string, envTagOptions, error) {
+	var opts envTagOptions
+	parts := strings.Split(tag, ",")
+
+	for len(parts) > 1 {
+		if !opts.parseOption(strings.TrimSpace(parts[len(parts)-1])) {
+			break
+		}
+		parts = parts[:len(parts)-1]
+	}
+
+	var seen envTagOptions
+	for i, part := range parts[1:] {
+		if !seen.parseOption(strings.TrimSpace(part)) {
+			continue
+		}
+		for _, unknown := range parts[i+2:] {
+			if unknown = strings.TrimSpace(unknown); !seen.parseOption(unknown) {
+				return "", envTagOptions{}, fmt.Errorf("unknown option %q in env tag %q", unknown, tag)
+			}
+		}
+	}
+
+	return strings.Join(parts, ","), opts, nil
+}
+
+// Apply a single option from an `env` tag, reporting whether it was
+// recognized.
+func (opts *envTagOptions) parseOption(token string) bool {
+	switch {
+	case token == "required":
+		opts.required = true
+	case token == "optional":
+		opts.required = false
+	case token == "trim":
+		opts.trim = true
+	case strings.HasPrefix(token, "required_if="):
+		c := strings.TrimPrefix(token, "required_if=")
+		if i := strings.Index(c, ":"); i >= 0 {
+			opts.requiredIf = &condition{name: c[:i], value: c[i+1:]}
+		} else {
+			opts.requiredIf = &condition{name: c}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// Report whether a field of the given type should be left at its zero value
+// when its variable isn't set at all, as opposed to being set but empty.
+func zeroWhenUnset(t reflect.Type) bool {
+	switch t {
+	case nullStringType, nullInt64Type, nullBoolType, nullFloat64Type:
+		return true
+	}
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Slice {
+		return t.Elem().Elem().Kind() != reflect.Uint8
+	}
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Map
+}
+
+// Report whether a type is a string or []byte, or a pointer to one.
+func isStringish(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.String ||
+		(t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+}
+
+// Strip matching single or double quotes from around a value. Escape
+// sequences in double quoted values are interpreted, while single quoted
+// values are taken literally. Unquoted values are returned as-is.
+func unquote(s string) (string, error) {
+	if s == "" {
+		return s, nil
+	}
+
+	first, last := s[0], s[len(s)-1]
+	isQuote := func(c byte) bool { return c == '"' || c == '\'' }
+
+	switch {
+	case !isQuote(first) && !isQuote(last):
+		return s, nil
+	case len(s) < 2 || first != last:
+		return "", errors.New("unbalanced quotes")
+	case first == '\'':
+		return s[1 : len(s)-1], nil
+	default:
+		return strconv.Unquote(s)
+	}
+}
+
+// SetValue converts a string and places it in v according to v's type, the
+// same way a struct field would be set from an environment variable. This
+// allows babyenv's conversions to be reused elsewhere, such as for flags:
+//
+//     var timeout time.Duration
+//     err := babyenv.SetValue(reflect.ValueOf(&timeout).Elem(), "30s")
+//
+// Options such as WithTypeParser and WithBoolWords are taken into account,
+// but there are no tags to configure the conversion with, so lists use the
+// default separator. v must be settable.
+func SetValue(v reflect.Value, s string, opts ...Option) error {
+	if !v.CanSet() {
+		return errors.New("value can't be set")
+	}
+	return setValue(v, s, "", newOptions(opts))
+}
+
+// Set a field according to its kind, converting the string value as
+// necessary. Some kinds, such as slices, can be further configured with the
+// field's tags.
+func setValue(field reflect.Value, val string, tags reflect.StructTag, o *options) error {
+
+	// Parsers registered for a type take precedence over everything else
+	if parse, ok := o.typeParsers[field.Type()]; ok {
+		return setParsed(field, val, parse)
+	}
+
+	// Structs tagged with `kv:"true"` are set from a list of key:value
+	// pairs, one for each field
+	if tags.Get("kv") == "true" {
+		return setKV(field, val, tags, o)
+	}
+
+	// Plain strings are by far the most common, so skip the checks below
+	if field.Type() == stringType {
+		field.SetString(val)
+		return nil
+	}
+
+	val = o.boolWord(field.Type(), val)
+
+	// Some types are structs or pointers to structs, so we need to check for
+	// them by type before looking at kinds
+	switch field.Type() {
+	case bigIntType:
+		return setBigInt(field, val)
+	case bigFloatType:
+		return setBigFloat(field, val)
+	case nullStringType, nullInt64Type, nullBoolType, nullFloat64Type:
+		return setSQLNull(field, val)
+	case durationType, durationPtrType:
+		return setElem(field, val)
+	}
+
+	// Any other type that knows how to parse itself takes precedence over
+	// the kind of value it happens to be
+	if isUnmarshaler(field.Type()) {
+		return setUnmarshaler(field, val)
+	}
+	if isTextUnmarshaler(field.Type()) {
+		return setText(field, val)
+	}
+
+	switch field.Kind() {
+
+	case reflect.String:
+		field.SetString(val)
+
+	case reflect.Bool:
+		return setBool(field, val)
+
+	case reflect.Int:
+		return setInt(field, val)
+
+	case reflect.Int64:
+		return setInt64(field, val)
+
+	case reflect.Uint, reflect.Uint64:
+		return setUint(field, val)
+
+	case reflect.Float32, reflect.Float64:
+		return setFloat(field, val)
+
+	case reflect.Interface:
+		if !o.interfaceInference {
+			return &ErrorUnsupportedType{Type: field.Type()}
+		}
+		return setInterface(field, val)
+
+	// Slices are a whole can of worms
+	case reflect.Slice:
+		switch field.Type().Elem().Kind() {
+
+		// []uint8 is an alias for []byte. The value is taken literally
+		// unless we've been asked to read a list of integers.
+		case reflect.Uint8:
+			if tags.Get("elements") == "int" {
+				return setSlice(field, val, tags, o)
+			}
+			field.SetBytes([]byte(val))
+
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float32, reflect.Float64:
+			return setSlice(field, val, tags, o)
+
+		// Slices of pointers to scalars, such as []*time.Duration
+		case reflect.Ptr:
+			if !isScalar(field.Type().Elem().Elem()) {
+				return &ErrorUnsupportedType{Type: field.Type()}
+			}
+			return setSlice(field, val, tags, o)
+
+		// Slices of structs are read from a JSON array
+		case reflect.Struct:
+			return setStructSlice(field, val)
+
+		default:
+			return &ErrorUnsupportedType{Type: field.Type()}
+
+		}
+
+	// Arrays are like slices, but the number of elements has to match
+	case reflect.Array:
+		switch field.Type().Elem().Kind() {
+
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float32, reflect.Float64:
+			return setArray(field, val, tags, o)
+
+		default:
+			return &ErrorUnsupportedType{Type: field.Type()}
+
+		}
+
+	// Maps are keyed by strings, but can hold any of the scalar types
+	case reflect.Map:
+		if field.Type().Key().Kind() != reflect.String {
+			return &ErrorUnsupportedType{Type: field.Type()}
+		}
+
+		switch field.Type().Elem().Kind() {
+
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float32, reflect.Float64:
+			return setMap(field, val, tags, o)
+
+		case reflect.Ptr:
+			if !isScalar(field.Type().Elem().Elem()) {
+				return &ErrorUnsupportedType{Type: field.Type()}
+			}
+			return setMap(field, val, tags, o)
+
+		// Maps of structs are read from a JSON object
+		case reflect.Struct:
+			return setStructMap(field, val)
+
+		// Maps of interface{} have the type of each value inferred
+		case reflect.Interface:
+			if !o.interfaceInference {
+				return &ErrorUnsupportedType{Type: field.Type()}
+			}
+			return setMap(field, val, tags, o)
+
+		default:
+			return &ErrorUnsupportedType{Type: field.Type()}
+
+		}
+
+	// Pointers are also a whole other can of worms
+	case reflect.Ptr:
+		ptr := field.Type().Elem()
+
+		switch ptr.Kind() {
+
+		case reflect.String:
+			str := reflect.New(ptr)
+			str.Elem().SetString(val)
+			field.Set(str)
+
+		case reflect.Bool:
+			return setBoolPointer(field, val)
+
+		case reflect.Int:
+			return setIntPointer(field, val)
+
+		case reflect.Int64:
+			return setInt64Pointer(field, val)
+
+		// Pointers to pointers, such as **int, which some generated code
+		// uses. We allocate the outer pointer and set the inner one.
+		case reflect.Ptr:
+			inner := reflect.New(ptr)
+			if err := setValue(inner.Elem(), val, tags, o); err != nil {
+				return err
+			}
+			field.Set(inner)
+
+		// A poiner to a slice!! Whole other level
+		case reflect.Slice:
+
+			switch ptr.Elem().Kind() {
+
+			// *[]uint8 is an alias for *[]byte
+			case reflect.Uint8:
+				bytes := reflect.New(ptr)
+				bytes.Elem().SetBytes([]byte(val))
+				field.Set(bytes)
+
+			case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float32, reflect.Float64:
+				slice := reflect.New(ptr)
+				if err := setSlice(slice.Elem(), val, tags, o); err != nil {
+					return err
+				}
+				field.Set(slice)
+
+			default:
+				return &ErrorUnsupportedType{Type: field.Type()}
+
+			}
+
+		default:
+			return &ErrorUnsupportedType{Type: field.Type()}
+		}
+
+	default:
+		return &ErrorUnsupportedType{Type: field.Type()}
+	}
+
+	return nil
+}
+
+// Parse a base 10 integer, allowing underscores between digits as separators
+// as in Go source, such as 10_000_000.
+func parseInt(s string, bitSize int) (int64, error) {
+	s, err := stripUnderscores(s)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, bitSize)
+}
 
-				default:
-					return &ErrorUnsupportedType{field.Type()}
+// Parse a base 10 unsigned integer, allowing underscores between digits as
+// separators.
+func parseUint(s string, bitSize int) (uint64, error) {
+	if strings.HasPrefix(s, "-") {
+		return 0, errNegativeUnsigned
+	}
+	s, err := stripUnderscores(s)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(s, 10, bitSize)
+}
 
-				}
+// Parse a float, allowing underscores between digits as separators.
+func parseFloat(s string, bitSize int) (float64, error) {
+	s, err := stripUnderscores(s)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, bitSize)
+}
 
-			default:
-				return &ErrorUnsupportedType{field.Type()}
-			}
+// Remove underscores used as digit separators. Underscores must sit between
+// two digits.
+func stripUnderscores(s string) (string, error) {
+	if !strings.Contains(s, "_") {
+		return s, nil
+	}
 
-		default:
-			return &ErrorUnsupportedType{field.Type()}
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '_' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i == 0 || i == len(s)-1 || !isDigit(s[i-1]) || !isDigit(s[i+1]) {
+			return "", fmt.Errorf("misplaced underscore in %s", s)
 		}
+	}
+	return b.String(), nil
+}
+
+// Report whether a type is an integer or float, or a pointer to one.
+func isNumeric(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return t != durationType
+	}
+	return false
+}
 
+// Remove grouping separators from the whole part of a number, as in
+// 1,000,000. Every group after the first must have three digits. When the
+// separator is a period the decimal mark is taken to be a comma, as in
+// 1.000,5, and is replaced with a period.
+func stripThousands(s, sep string) (string, error) {
+	if !strings.Contains(s, sep) {
+		return s, nil
 	}
 
-	return nil
+	mark := "."
+	if sep == "." {
+		mark = ","
+	}
+	whole, frac := s, ""
+	if i := strings.Index(s, mark); i >= 0 {
+		whole, frac = s[:i], "."+s[i+len(mark):]
+	}
+	sign := ""
+	if strings.HasPrefix(whole, "-") || strings.HasPrefix(whole, "+") {
+		sign, whole = whole[:1], whole[1:]
+	}
+
+	groups := strings.Split(whole, sep)
+	for i, g := range groups {
+		if g == "" || len(g) > 3 || (i > 0 && len(g) != 3) {
+			return "", fmt.Errorf("misplaced thousands separator in %s", s)
+		}
+	}
+	return sign + strings.Join(groups, "") + frac, nil
+}
+
+// Translate one of the words given with WithBoolWords into "true" or "false"
+// for bool fields, pointers to them and sql.NullBool. Other values are
+// returned as they are.
+func (o *options) boolWord(t reflect.Type, s string) string {
+	if len(o.trueWords) == 0 && len(o.falseWords) == 0 {
+		return s
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Bool && t != nullBoolType {
+		return s
+	}
+	for _, w := range o.trueWords {
+		if strings.EqualFold(s, w) {
+			return "true"
+		}
+	}
+	for _, w := range o.falseWords {
+		if strings.EqualFold(s, w) {
+			return "false"
+		}
+	}
+	return s
 }
 
 func setBool(v reflect.Value, s string) error {
@@ -349,6 +2158,7 @@ func setBool(v reflect.Value, s string) error {
 	return nil
 }
 
+// Set an int, allowing whatever range an int holds on this platform.
 func setInt(v reflect.Value, s string) error {
 	if s == "" {
 		// Default to 0
@@ -356,7 +2166,7 @@ func setInt(v reflect.Value, s string) error {
 		return nil
 	}
 
-	n, err := strconv.ParseInt(s, 10, 32)
+	n, err := parseInt(s, strconv.IntSize)
 	if err != nil {
 		return err
 	}
@@ -371,7 +2181,7 @@ func setInt64(v reflect.Value, s string) error {
 		return nil
 	}
 
-	n, err := strconv.ParseInt(s, 10, 64)
+	n, err := parseInt(s, 64)
 	if err != nil {
 		return err
 	}
@@ -379,54 +2189,560 @@ func setInt64(v reflect.Value, s string) error {
 	return nil
 }
 
-func setBoolPointer(v reflect.Value, s string) error {
+// Set an unsigned integer. Negative values are rejected up front, since
+// strconv's complaint about them doesn't make the problem clear.
+func setUint(v reflect.Value, s string) error {
 	if s == "" {
-		// Default to false
-		b := false
-		v.Set(reflect.ValueOf(&b))
+		// Default to 0
+		v.SetUint(0)
 		return nil
 	}
 
-	b, err := strconv.ParseBool(s)
+	n, err := parseUint(s, v.Type().Bits())
 	if err != nil {
 		return err
 	}
-
-	v.Set(reflect.ValueOf(&b))
+	v.SetUint(n)
 	return nil
 }
 
-func setIntPointer(v reflect.Value, s string) error {
+func setFloat(v reflect.Value, s string) error {
 	if s == "" {
 		// Default to 0
-		n := 0
-		v.Set(reflect.ValueOf(&n))
+		v.SetFloat(0)
+		return nil
+	}
+
+	f, err := parseFloat(s, v.Type().Bits())
+	if err != nil {
+		return err
+	}
+	v.SetFloat(f)
+	return nil
+}
+
+// Split a value on the separator in the `sep` tag, which defaults to a comma,
+// and set each element of a slice. Whitespace around elements is ignored.
+func setSlice(v reflect.Value, s string, tags reflect.StructTag, o *options) error {
+	if s == "" {
+		// Default to an empty slice
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
 		return nil
 	}
 
-	i64, err := strconv.ParseInt(s, 10, 32)
+	parts, err := o.splitList(s, separator(tags))
 	if err != nil {
 		return err
 	}
-	i := int(i64)
+	slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+	for i, part := range parts {
+		elem := slice.Index(i)
+		if err := setElem(elem, o.boolWord(elem.Type(), strings.TrimSpace(part))); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
 
-	v.Set(reflect.ValueOf(&i))
+	v.Set(slice)
 	return nil
 }
 
-func setInt64Pointer(v reflect.Value, s string) error {
+// Split a value on the separator in the `sep` tag and set each element of a
+// fixed-size array. The number of elements must match the array's length.
+func setArray(v reflect.Value, s string, tags reflect.StructTag, o *options) error {
 	if s == "" {
-		// Default to 0
-		n := 0
-		v.Set(reflect.ValueOf(&n))
+		// Default to the zero value
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	parts, err := o.splitList(s, separator(tags))
+	if err != nil {
+		return err
+	}
+	if len(parts) != v.Len() {
+		return fmt.Errorf("expected %d elements, got %d", v.Len(), len(parts))
+	}
+
+	arr := reflect.New(v.Type()).Elem()
+	for i, part := range parts {
+		elem := arr.Index(i)
+		if err := setElem(elem, o.boolWord(elem.Type(), strings.TrimSpace(part))); err != nil {
+			return fmt.Errorf("element %d: %w", i, err)
+		}
+	}
+
+	v.Set(arr)
+	return nil
+}
+
+// Split a value into key=value entries on the separator in the `sep` tag,
+// which defaults to a comma, and set them in a map. Whitespace around keys and
+// values is ignored.
+func setMap(v reflect.Value, s string, tags reflect.StructTag, o *options) error {
+	m := reflect.MakeMap(v.Type())
+
+	for _, entry := range strings.Split(s, separator(tags)) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			return fmt.Errorf("malformed entry %q; expected key=value", entry)
+		}
+
+		key := strings.TrimSpace(entry[:i])
+		elem := reflect.New(v.Type().Elem()).Elem()
+		set := setElem
+		if elem.Kind() == reflect.Interface {
+			set = setInterface
+		}
+		if err := set(elem, o.boolWord(elem.Type(), strings.TrimSpace(entry[i+1:]))); err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+
+		m.SetMapIndex(reflect.ValueOf(key).Convert(v.Type().Key()), elem)
+	}
+
+	v.Set(m)
+	return nil
+}
+
+// Split a list into its elements. With WithCSVLists, a list with a
+// single-character separator other than a newline is read as a CSV record, so
+// elements can be quoted to include the separator.
+func (o *options) splitList(s, sep string) ([]string, error) {
+	if !o.csvLists || utf8.RuneCountInString(sep) != 1 || strings.ContainsAny(sep, "\r\n\"") {
+		return strings.Split(s, sep), nil
+	}
+
+	r := csv.NewReader(strings.NewReader(s))
+	r.Comma, _ = utf8.DecodeRuneInString(sep)
+	r.TrimLeadingSpace = true
+	parts, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("could not parse list: %w", err)
+	}
+	return parts, nil
+}
+
+// Get the separator for a list from the `sep` tag. A few named separators are
+// supported for characters that are awkward to write in a tag.
+func separator(tags reflect.StructTag) string {
+	switch sep := tags.Get("sep"); sep {
+	case "":
+		return ","
+	case "newline":
+		return "\n"
+	case "tab":
+		return "\t"
+	case "space":
+		return " "
+	default:
+		return sep
+	}
+}
+
+// Set a single element of a slice using the scalar setters.
+func setElem(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		return setDuration(v, s)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		return setBool(v, s)
+	case reflect.Int:
+		return setInt(v, s)
+	case reflect.Int64:
+		return setInt64(v, s)
+	case reflect.Float32, reflect.Float64:
+		return setFloat(v, s)
+	case reflect.Uint8:
+		return setByte(v, s)
+	case reflect.Uint, reflect.Uint64:
+		return setUint(v, s)
+	case reflect.Ptr:
+		ptr := reflect.New(v.Type().Elem())
+		if err := setElem(ptr.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(ptr)
+	default:
+		return &ErrorUnsupportedType{Type: v.Type()}
+	}
+	return nil
+}
+
+// Report whether a type is one of the scalar types that can be held by
+// slices, arrays and maps.
+func isScalar(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// Set a time.Duration from a string such as 1h30m. An empty value results in
+// zero.
+func setDuration(v reflect.Value, s string) error {
+	if s == "" {
+		v.SetInt(0)
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	v.SetInt(int64(d))
+	return nil
+}
+
+// Set a byte from an integer between 0 and 255.
+func setByte(v reflect.Value, s string) error {
+	n, err := parseUint(s, 8)
+	if err != nil {
+		return err
+	}
+	v.SetUint(n)
+	return nil
+}
+
+// Set an interface{} field to an int, float64, bool or string, whichever the
+// value looks like, in that order.
+func setInterface(v reflect.Value, s string) error {
+	if s == "" {
+		// Default to nil
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	var inferred interface{} = s
+
+	if n, err := strconv.ParseInt(s, 10, strconv.IntSize); err == nil {
+		inferred = int(n)
+	} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+		inferred = f
+	} else if b, err := strconv.ParseBool(s); err == nil {
+		inferred = b
+	}
+
+	iv := reflect.ValueOf(inferred)
+	if !iv.Type().AssignableTo(v.Type()) {
+		return &ErrorUnsupportedType{Type: v.Type()}
+	}
+	v.Set(iv)
+	return nil
+}
+
+// Set a field using a parser registered with WithTypeParser, making sure the
+// value it returns fits.
+func setParsed(v reflect.Value, s string, parse func(string) (interface{}, error)) error {
+	parsed, err := parse(s)
+	if err != nil {
+		return err
+	}
+
+	val := reflect.ValueOf(parsed)
+	if !val.IsValid() {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	if !val.Type().AssignableTo(v.Type()) {
+		return fmt.Errorf("parser for %v returned a %v", v.Type(), val.Type())
+	}
+	v.Set(val)
+	return nil
+}
+
+var (
+	unmarshalerType       = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// Report whether a type, a pointer to it, or the type it points to implements
+// Unmarshaler.
+func isUnmarshaler(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(unmarshalerType) ||
+		(t.Kind() == reflect.Ptr && t.Implements(unmarshalerType))
+}
+
+// Set a field whose type implements Unmarshaler. Pointers are allocated as
+// needed. An empty value results in the zero value, so pointers are left nil.
+func setUnmarshaler(v reflect.Value, s string) error {
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr && v.Type().Implements(unmarshalerType) {
+		ptr := reflect.New(v.Type().Elem())
+		if err := ptr.Interface().(Unmarshaler).UnmarshalEnv(s); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	return v.Addr().Interface().(Unmarshaler).UnmarshalEnv(s)
+}
+
+// Report whether a type, a pointer to it, or the type it points to implements
+// encoding.TextUnmarshaler.
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(textUnmarshalerType) ||
+		(t.Kind() == reflect.Ptr && t.Implements(textUnmarshalerType))
+}
+
+// Set a field whose type implements encoding.TextUnmarshaler. Pointers are
+// allocated as needed. An empty value results in the zero value.
+func setText(v reflect.Value, s string) error {
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
 		return nil
 	}
 
-	i, err := strconv.ParseInt(s, 10, 64)
+	if v.Kind() == reflect.Ptr && v.Type().Implements(textUnmarshalerType) {
+		ptr := reflect.New(v.Type().Elem())
+		if err := ptr.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+
+	return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+}
+
+// Set a map of structs from a JSON object whose values are objects. An empty
+// value results in an empty map.
+func setStructMap(v reflect.Value, s string) error {
+	m := reflect.MakeMap(v.Type())
+	if s != "" {
+		ptr := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(s), ptr.Interface()); err != nil {
+			return fmt.Errorf("could not parse JSON: %w", err)
+		}
+		m = ptr.Elem()
+	}
+	v.Set(m)
+	return nil
+}
+
+// Set a slice of structs from a JSON array of objects. An empty value results
+// in an empty slice.
+func setStructSlice(v reflect.Value, s string) error {
+	slice := reflect.MakeSlice(v.Type(), 0, 0)
+	if s != "" {
+		ptr := reflect.New(v.Type())
+		if err := json.Unmarshal([]byte(s), ptr.Interface()); err != nil {
+			return fmt.Errorf("could not parse JSON: %w", err)
+		}
+		slice = ptr.Elem()
+	}
+	v.Set(slice)
+	return nil
+}
+
+// Set a struct, or a pointer to one, from a list of key:value pairs such as
+// host:localhost,port:8080. Keys are matched to the struct's fields by their
+// variable names, ignoring case, and each value is set according to its
+// field's type and tags. Unknown keys are an error.
+func setKV(v reflect.Value, s string, tags reflect.StructTag, o *options) error {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return &ErrorUnsupportedType{Type: v.Type()}
+	}
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	st := reflect.New(t).Elem()
+	fields := cachedFields(t)
+
+	for _, entry := range strings.Split(s, separator(tags)) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.Index(entry, ":")
+		if i < 0 {
+			return fmt.Errorf("malformed entry %q; expected key:value", entry)
+		}
+		key := strings.TrimSpace(entry[:i])
+
+		var info *fieldInfo
+		for _, f := range fields {
+			if f.exported && strings.EqualFold(f.envVarName, key) {
+				info = f
+				break
+			}
+		}
+		if info == nil {
+			return fmt.Errorf("unknown key %q", key)
+		}
+
+		if err := setValue(st.Field(info.index), strings.TrimSpace(entry[i+1:]), info.tags, o); err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+	}
+
+	if v.Kind() == reflect.Ptr {
+		v.Set(st.Addr())
+	} else {
+		v.Set(st)
+	}
+	return nil
+}
+
+// Decode a hex string into a []byte or a fixed-size byte array. The decoded
+// length must match the length of an array.
+func setHex(v reflect.Value, s string) error {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("could not decode hex: %w", err)
+	}
+	return setBinary(v, b, "hex")
+}
+
+// Decode a standard base64 string into a []byte or a fixed-size byte array.
+func setBase64(v reflect.Value, s string) error {
+	b, err := base64.StdEncoding.DecodeString(s)
 	if err != nil {
+		return fmt.Errorf("could not decode base64: %w", err)
+	}
+	return setBinary(v, b, "base64")
+}
+
+// Set a field from decoded bytes. Types implementing
+// encoding.BinaryUnmarshaler are given the bytes to unmarshal, and pointers to
+// them are allocated as needed.
+func setBinary(v reflect.Value, b []byte, enc string) error {
+	t := v.Type()
+
+	if t.Kind() == reflect.Ptr && t.Implements(binaryUnmarshalerType) {
+		ptr := reflect.New(t.Elem())
+		if err := ptr.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if reflect.PtrTo(t).Implements(binaryUnmarshalerType) {
+		return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+	}
+
+	switch {
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		v.SetBytes(b)
+	case t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8:
+		if len(b) != t.Len() {
+			return fmt.Errorf("expected %d bytes, got %d", t.Len(), len(b))
+		}
+		reflect.Copy(v, reflect.ValueOf(b))
+	default:
+		return fmt.Errorf("%s encoding is not supported for type %v", enc, t)
+	}
+	return nil
+}
+
+// Set one of the database/sql Null types, marking it valid. Empty values are
+// left invalid, except for sql.NullString.
+func setSQLNull(v reflect.Value, s string) error {
+	if s == "" && v.Type() != nullStringType {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Type() {
+	case nullStringType:
+		v.Set(reflect.ValueOf(sql.NullString{String: s, Valid: true}))
+	case nullInt64Type:
+		n, err := parseInt(s, 64)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(sql.NullInt64{Int64: n, Valid: true}))
+	case nullBoolType:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(sql.NullBool{Bool: b, Valid: true}))
+	case nullFloat64Type:
+		f, err := parseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(sql.NullFloat64{Float64: f, Valid: true}))
+	}
+	return nil
+}
+
+func setBoolPointer(v reflect.Value, s string) error {
+	return setPointer(v, s, setBool)
+}
+
+func setIntPointer(v reflect.Value, s string) error {
+	return setPointer(v, s, setInt)
+}
+
+func setInt64Pointer(v reflect.Value, s string) error {
+	return setPointer(v, s, setInt64)
+}
+
+// Allocate a pointer and set the value it points to with set. The pointer is
+// built from the field's type, so pointers to named types, such as *Level for
+// `type Level string`, can be assigned.
+func setPointer(v reflect.Value, s string, set func(reflect.Value, string) error) error {
+	ptr := reflect.New(v.Type().Elem())
+	if err := set(ptr.Elem(), s); err != nil {
 		return err
 	}
+	v.Set(ptr)
+	return nil
+}
+
+func setBigInt(v reflect.Value, s string) error {
+	n := new(big.Int)
+	if s == "" {
+		// Default to 0
+		v.Set(reflect.ValueOf(n))
+		return nil
+	}
+
+	if _, ok := n.SetString(s, 10); !ok {
+		return errors.New("invalid integer")
+	}
+
+	v.Set(reflect.ValueOf(n))
+	return nil
+}
+
+func setBigFloat(v reflect.Value, s string) error {
+	f := new(big.Float).SetPrec(bigFloatPrec)
+	if s == "" {
+		// Default to 0
+		v.Set(reflect.ValueOf(f))
+		return nil
+	}
+
+	if _, ok := f.SetString(s); !ok {
+		return errors.New("invalid float")
+	}
 
-	v.Set(reflect.ValueOf(&i))
+	v.Set(reflect.ValueOf(f))
 	return nil
 }
@@ -1,9 +1,19 @@
 package babyenv
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"math/big"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParse(t *testing.T) {
@@ -218,6 +228,40 @@ func TestRequiredFlag(t *testing.T) {
 	}
 }
 
+func TestWrongKind(t *testing.T) {
+	type config struct {
+		Name string `env:"NAME"`
+	}
+
+	n := 42
+	tests := []struct {
+		cfg      interface{}
+		expected string
+	}{
+		{config{}, "expected a pointer to a struct, got a struct"},
+		{n, "expected a pointer to a struct, got an int"},
+		{&n, "expected a pointer to a struct, got a pointer to an int"},
+		{&map[string]string{}, "expected a pointer to a struct, got a pointer to a map"},
+		{nil, "expected a pointer to a struct, got nil"},
+	}
+
+	for _, test := range tests {
+		err := Parse(test.cfg)
+		if !errors.Is(err, ErrorNotAStructPointer) {
+			t.Errorf("expected error parsing %T to match ErrorNotAStructPointer; got %v", test.cfg, err)
+			continue
+		}
+		if err.Error() != test.expected {
+			t.Errorf("unexpected error parsing %T; expected %#v, got %#v", test.cfg, test.expected, err.Error())
+		}
+	}
+
+	var wrongKind *ErrorWrongKind
+	if err := Parse(&n); !errors.As(err, &wrongKind) || wrongKind.Kind != reflect.Int || !wrongKind.Pointer {
+		t.Errorf("expected an ErrorWrongKind for a pointer to an int; got %#v", err)
+	}
+}
+
 func TestUnexportedFieldBehavior(t *testing.T) {
 	type a struct {
 		a bool
@@ -236,4 +280,2511 @@ func TestUnexportedFieldBehavior(t *testing.T) {
 	if err := Parse(&bEnv); err == nil {
 		t.Error("expected an error parsing a field with an 'env' tag on an unexported struct")
 	}
+
+	type c struct {
+		name string `env:"UNEXPORTED_NAME"`
+		port int    `env:"UNEXPORTED_PORT" default:"8000"`
+	}
+
+	var cEnv c
+	if err := Parse(&cEnv, WithLookuper(MapLookuper{"UNEXPORTED_NAME": "Jane"}), WithAllowUnexported()); err != nil {
+		t.Errorf("error while parsing unexported fields with WithAllowUnexported: %v", err)
+	}
+	if cEnv.name != "Jane" || cEnv.port != 8000 {
+		t.Errorf("failed setting unexported fields; got %#v", cEnv)
+	}
+}
+
+func TestLiteralDefaults(t *testing.T) {
+	type config struct {
+		A string `env:"A" default:"-"`
+		B string `env:"B" default:"\\-"`
+		C string `env:"C" default:"\\@home"`
+		D string `env:"D" default:"\\\\server"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{}), WithValidateDefaults()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := config{A: "", B: "-", C: "@home", D: `\server`}
+	if cfg != expected {
+		t.Errorf("failed parsing literal defaults; expected %#v, got %#v", expected, cfg)
+	}
+}
+
+func TestProgrammaticDefaults(t *testing.T) {
+	type config struct {
+		Level   string `env:"LOG_LEVEL" default:"info"`
+		Workers int    `env:"WORKERS" default:"4"`
+		Name    string `env:"NAME"`
+	}
+
+	defaults := map[string]string{
+		"LOG_LEVEL": "debug",
+		"NAME":      "Jane",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"NAME": "Joe"}), WithDefaults(defaults)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := config{Level: "debug", Workers: 4, Name: "Joe"}
+	if cfg != expected {
+		t.Errorf("unexpected precedence of defaults; expected %#v, got %#v", expected, cfg)
+	}
+}
+
+func TestDefaultFuncs(t *testing.T) {
+	type config struct {
+		A string `env:"A" default:"@dataDir"`
+		B int    `env:"B" default:"@workers"`
+	}
+
+	a := "/home/jane/.local/share"
+	b := 8
+
+	os.Unsetenv("A")
+	os.Unsetenv("B")
+
+	var cfg config
+	if err := Parse(&cfg, WithDefaultFuncs(map[string]func() (string, error){
+		"dataDir": func() (string, error) { return a, nil },
+		"workers": func() (string, error) { return strconv.Itoa(b), nil },
+	})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != a {
+		t.Errorf("failed computing string default; expected %#v, got %#v", a, cfg.A)
+	}
+	if cfg.B != b {
+		t.Errorf("failed computing int default; expected %#v, got %#v", b, cfg.B)
+	}
+
+	type errConfig struct {
+		DataDir string `env:"DATA_DIR" default:"@dataDir"`
+	}
+
+	os.Unsetenv("DATA_DIR")
+
+	errNoHome := errors.New("no home directory")
+
+	var errCfg errConfig
+	if err := Parse(&errCfg, WithDefaultFuncs(map[string]func() (string, error){
+		"dataDir": func() (string, error) { return "", errNoHome },
+	})); !errors.Is(err, errNoHome) {
+		t.Errorf("expected the default func's error to be wrapped; got %v", err)
+	} else if !strings.Contains(err.Error(), "field DataDir") {
+		t.Errorf("expected error to name the field; got %v", err)
+	}
+}
+
+func TestJSONEncoding(t *testing.T) {
+	type limits struct {
+		Requests int            `json:"requests"`
+		Burst    int            `json:"burst"`
+		Routes   map[string]int `json:"routes"`
+	}
+	type config struct {
+		Limits limits `env:"LIMITS" encoding:"json"`
+	}
+
+	os.Setenv("LIMITS", `{"requests": 100, "burst": 10, "routes": {"/": 5}}`)
+
+	var cfg config
+	if err := Parse(&cfg); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.Limits.Requests != 100 || cfg.Limits.Burst != 10 || cfg.Limits.Routes["/"] != 5 {
+		t.Errorf("failed parsing JSON; got %#v", cfg.Limits)
+	}
+
+	os.Setenv("LIMITS", `{"requests": "lots"}`)
+	if err := Parse(&cfg); err == nil {
+		t.Error("expected an error parsing invalid JSON")
+	} else if !strings.Contains(err.Error(), "LIMITS") {
+		t.Errorf("expected error to name the env var; got %v", err)
+	}
+}
+
+func TestSlicePointers(t *testing.T) {
+	type config struct {
+		A *[]string `env:"A"`
+		B *[]int    `env:"B" sep:";"`
+		C *[]string `env:"C"`
+		D *[]int    `env:"D"`
+		E *[]int    `env:"E" default:"1,2"`
+	}
+
+	env := MapLookuper{"A": "a,b,c", "B": "1;2;3", "D": ""}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A == nil || !reflect.DeepEqual(*cfg.A, []string{"a", "b", "c"}) {
+		t.Errorf("failed parsing *[]string; got %#v", cfg.A)
+	}
+	if cfg.B == nil || !reflect.DeepEqual(*cfg.B, []int{1, 2, 3}) {
+		t.Errorf("failed parsing *[]int; got %#v", cfg.B)
+	}
+	if cfg.C != nil {
+		t.Errorf("expected unset *[]string to be nil; got %#v", *cfg.C)
+	}
+	if cfg.D == nil || len(*cfg.D) != 0 {
+		t.Errorf("expected empty *[]int to point at an empty slice; got %#v", cfg.D)
+	}
+	if cfg.E == nil || !reflect.DeepEqual(*cfg.E, []int{1, 2}) {
+		t.Errorf("failed parsing *[]int default; got %#v", cfg.E)
+	}
+}
+
+func TestStrictTags(t *testing.T) {
+	type config struct {
+		Name string `env:"NAME,required" default:"Jane"`
+	}
+
+	env := MapLookuper{"NAME": "Joe"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("expected required field with a default to be allowed without strict tags; got %v", err)
+	}
+
+	err := Parse(&cfg, WithLookuper(env), WithStrictTags())
+	if err == nil {
+		t.Fatal("expected an error for a required field with a default")
+	}
+	if !strings.Contains(err.Error(), "Name") {
+		t.Errorf("expected error to name the field; got %v", err)
+	}
+}
+
+func TestByteElements(t *testing.T) {
+	type config struct {
+		Raw  []byte `env:"BYTES"`
+		Ints []byte `env:"BYTES" elements:"int"`
+	}
+
+	env := MapLookuper{"BYTES": "10,20,30"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if string(cfg.Raw) != "10,20,30" {
+		t.Errorf("expected []byte to be taken literally; got %#v", cfg.Raw)
+	}
+	if !reflect.DeepEqual(cfg.Ints, []byte{10, 20, 30}) {
+		t.Errorf("failed parsing []byte from integers; got %#v", cfg.Ints)
+	}
+
+	env["BYTES"] = "10,256"
+	if err := Parse(&cfg, WithLookuper(env)); err == nil {
+		t.Error("expected an error parsing a byte above 255")
+	}
+}
+
+func TestPresence(t *testing.T) {
+	type config struct {
+		Debug   bool `env:"DEBUG" presence:"true"`
+		Verbose bool `env:"VERBOSE" presence:"true"`
+		Quiet   bool `env:"QUIET" presence:"true"`
+	}
+
+	env := MapLookuper{"DEBUG": "", "QUIET": "false"}
+
+	cfg := config{Verbose: true}
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := config{Debug: true, Verbose: false, Quiet: true}
+	if cfg != expected {
+		t.Errorf("failed parsing presence bools; expected %#v, got %#v", expected, cfg)
+	}
+
+	type requiredConfig struct {
+		Debug bool `env:"DEBUG,required" presence:"true"`
+	}
+
+	var req requiredConfig
+	if err := Parse(&req, WithLookuper(env)); err != nil || !req.Debug {
+		t.Errorf("expected a set but empty variable to satisfy a required presence bool; got %v, %#v", err, req.Debug)
+	}
+	if err := Parse(&req, WithLookuper(MapLookuper{})); !errors.Is(err, ErrorMissingRequired) {
+		t.Errorf("expected an unset variable to fail a required presence bool; got %v", err)
+	}
+	if missing, err := Validate(req, WithLookuper(env)); err != nil || len(missing) != 0 {
+		t.Errorf("expected Validate to accept a set but empty presence bool; got %v, %v", missing, err)
+	}
+}
+
+func TestHexEncoding(t *testing.T) {
+	type config struct {
+		Key [32]byte `env:"KEY" encoding:"hex"`
+		IV  []byte   `env:"IV" encoding:"hex"`
+	}
+
+	key := "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+	env := MapLookuper{"KEY": key, "IV": "cafe"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	for i, b := range cfg.Key {
+		if int(b) != i {
+			t.Errorf("failed decoding hex into [32]byte; got %x", cfg.Key)
+			break
+		}
+	}
+	if string(cfg.IV) != "\xca\xfe" {
+		t.Errorf("failed decoding hex into []byte; got %x", cfg.IV)
+	}
+
+	env["KEY"] = "cafe"
+	if err := Parse(&cfg, WithLookuper(env)); err == nil {
+		t.Error("expected an error decoding too few bytes into [32]byte")
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	type config struct {
+		A *big.Int   `env:"A"`
+		B *big.Float `env:"B"`
+	}
+
+	a := new(big.Int).Mul(big.NewInt(math.MaxInt64), big.NewInt(10))
+	b, _ := new(big.Float).SetPrec(bigFloatPrec).SetString("92233720368547758070.5")
+
+	os.Setenv("A", a.String())
+	os.Setenv("B", "92233720368547758070.5")
+
+	var cfg config
+	if err := Parse(&cfg); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A == nil {
+		t.Errorf("failed parsing *big.Int; expected %v, got nil", a)
+	} else if cfg.A.Cmp(a) != 0 {
+		t.Errorf("failed parsing *big.Int; expected %v, got %v", a, cfg.A)
+	}
+	if cfg.B == nil {
+		t.Errorf("failed parsing *big.Float; expected %v, got nil", b)
+	} else if cfg.B.Cmp(b) != 0 {
+		t.Errorf("failed parsing *big.Float; expected %v, got %v", b, cfg.B)
+	}
+
+	type errConfig struct {
+		BigNum *big.Int `env:"BIG_NUM"`
+	}
+
+	os.Setenv("BIG_NUM", "12x")
+
+	var errCfg errConfig
+	if err := Parse(&errCfg); err == nil {
+		t.Error("expected an error parsing an invalid *big.Int")
+	} else if !strings.Contains(err.Error(), "BIG_NUM") {
+		t.Errorf("expected error to name the env var; got %v", err)
+	}
+}
+
+func TestDeprecatedNames(t *testing.T) {
+	type config struct {
+		Name string `env:"NAME" deprecated:"USERNAME,USER_NAME"`
+	}
+
+	var calls []string
+	handler := func(field, oldName, newName string) {
+		calls = append(calls, field+":"+oldName+":"+newName)
+	}
+
+	var cfg config
+	if err := Parse(&cfg,
+		WithLookuper(MapLookuper{"USER_NAME": "Jane"}),
+		WithDeprecationHandler(handler),
+	); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.Name != "Jane" {
+		t.Errorf("failed reading deprecated name; expected %#v, got %#v", "Jane", cfg.Name)
+	}
+	if len(calls) != 1 || calls[0] != "Name:USER_NAME:NAME" {
+		t.Errorf("expected the deprecation handler to fire exactly once; got %v", calls)
+	}
+
+	calls = nil
+	if err := Parse(&cfg,
+		WithLookuper(MapLookuper{"NAME": "Joe", "USERNAME": "Jane"}),
+		WithDeprecationHandler(handler),
+	); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.Name != "Joe" {
+		t.Errorf("expected the current name to take precedence; expected %#v, got %#v", "Joe", cfg.Name)
+	}
+	if len(calls) != 0 {
+		t.Errorf("expected the deprecation handler not to fire; got %v", calls)
+	}
+}
+
+func TestParseSlices(t *testing.T) {
+	type config struct {
+		A []float64 `env:"A"`
+		B []bool    `env:"B" sep:";"`
+		C []string  `env:"C"`
+		D []int     `env:"D"`
+	}
+
+	a := []float64{0.5, 1.25, 2}
+	b := []bool{true, false, true}
+	c := []string{"x", "y"}
+	d := []int{1, 2, 3}
+
+	os.Setenv("A", "0.5, 1.25, 2")
+	os.Setenv("B", "true;false;1")
+	os.Setenv("C", "x,y")
+	os.Setenv("D", "1,2,3")
+
+	var cfg config
+	if err := Parse(&cfg); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if !reflect.DeepEqual(cfg.A, a) {
+		t.Errorf("failed parsing []float64; expected %#v, got %#v", a, cfg.A)
+	}
+	if !reflect.DeepEqual(cfg.B, b) {
+		t.Errorf("failed parsing []bool; expected %#v, got %#v", b, cfg.B)
+	}
+	if !reflect.DeepEqual(cfg.C, c) {
+		t.Errorf("failed parsing []string; expected %#v, got %#v", c, cfg.C)
+	}
+	if !reflect.DeepEqual(cfg.D, d) {
+		t.Errorf("failed parsing []int; expected %#v, got %#v", d, cfg.D)
+	}
+}
+
+func TestSliceDefaults(t *testing.T) {
+	type config struct {
+		Ports []int    `env:"PORTS" default:"8000, 8001"`
+		Hosts []string `env:"HOSTS" default:"a;b" sep:";"`
+		Bad   []int    `env:"BAD" default:"1,x"`
+	}
+
+	var cfg config
+	err := Parse(&cfg, WithLookuper(MapLookuper{"BAD": "1"}))
+	if err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if expected := []int{8000, 8001}; !reflect.DeepEqual(cfg.Ports, expected) {
+		t.Errorf("failed parsing slice default; expected %#v, got %#v", expected, cfg.Ports)
+	}
+	if expected := []string{"a", "b"}; !reflect.DeepEqual(cfg.Hosts, expected) {
+		t.Errorf("failed parsing slice default with separator; expected %#v, got %#v", expected, cfg.Hosts)
+	}
+
+	var invalid *ErrorInvalidValue
+	if err := Parse(&cfg, WithLookuper(MapLookuper{})); !errors.As(err, &invalid) || invalid.Name != "BAD" {
+		t.Errorf("expected an ErrorInvalidValue for the bad element in the default; got %v", err)
+	}
+}
+
+func TestParseSlicesInvalidElement(t *testing.T) {
+	type floats struct {
+		Weights []float64 `env:"WEIGHTS"`
+	}
+	type bools struct {
+		Flags []bool `env:"FLAGS"`
+	}
+
+	os.Setenv("WEIGHTS", "0.5,heavy")
+	os.Setenv("FLAGS", "true,false,maybe")
+
+	var f floats
+	if err := Parse(&f); err == nil {
+		t.Error("expected an error parsing an invalid []float64 element")
+	} else if !strings.Contains(err.Error(), "WEIGHTS") || !strings.Contains(err.Error(), "element 1") {
+		t.Errorf("expected error to name the env var and index; got %v", err)
+	}
+
+	var b bools
+	if err := Parse(&b); err == nil {
+		t.Error("expected an error parsing an invalid []bool element")
+	} else if !strings.Contains(err.Error(), "FLAGS") || !strings.Contains(err.Error(), "element 2") {
+		t.Errorf("expected error to name the env var and index; got %v", err)
+	}
+}
+
+func TestParseEnvTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		name     string
+		required bool
+		trim     bool
+	}{
+		{"NAME", "NAME", false, false},
+		{"NAME,required", "NAME", true, false},
+		{"NAME, required", "NAME", true, false},
+		{"NAME,optional", "NAME", false, false},
+		{"A,B", "A,B", false, false},
+		{"A,B,required", "A,B", true, false},
+		{"weird.name-1:x,required", "weird.name-1:x", true, false},
+		{"NAME,required,trim", "NAME", true, true},
+		{"NAME,trim,required", "NAME", true, true},
+		{"NAME,trim", "NAME", false, true},
+		{"NAME,trim,required_if=ENV:prod", "NAME", false, true},
+	}
+
+	for _, test := range tests {
+		name, opts, err := parseEnvTag(test.tag)
+		if err != nil {
+			t.Errorf("error while parsing %#v: %v", test.tag, err)
+			continue
+		}
+		if name != test.name {
+			t.Errorf("failed parsing name from %#v; expected %#v, got %#v", test.tag, test.name, name)
+		}
+		if opts.required != test.required {
+			t.Errorf("failed parsing required flag from %#v; expected %#v, got %#v", test.tag, test.required, opts.required)
+		}
+		if opts.trim != test.trim {
+			t.Errorf("failed parsing trim flag from %#v; expected %#v, got %#v", test.tag, test.trim, opts.trim)
+		}
+	}
+
+	type config struct {
+		A string `env:"A,B,required"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"A,B": "xxx"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+	} else if cfg.A != "xxx" {
+		t.Errorf("failed parsing name containing a comma; expected %#v, got %#v", "xxx", cfg.A)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{})); err == nil {
+		t.Error("expected an error because of an unfulfilled 'require' flag")
+	}
+
+	for _, tag := range []string{"A,required,B", "S,required,tirm", "S,trim,tirm,required"} {
+		if _, _, err := parseEnvTag(tag); err == nil {
+			t.Errorf("expected an error for the unknown option in %#v", tag)
+		}
+	}
+
+	type typoConfig struct {
+		S string `env:"S,required,tirm"`
+	}
+
+	var typo typoConfig
+	if err := Parse(&typo, WithLookuper(MapLookuper{})); err == nil || !strings.Contains(err.Error(), "tirm") {
+		t.Errorf("expected an error naming the unknown option; got %v", err)
+	}
+}
+
+func TestDurations(t *testing.T) {
+	type config struct {
+		Timeout  time.Duration            `env:"TIMEOUT"`
+		Interval *time.Duration           `env:"INTERVAL" default:"1m"`
+		Timeouts map[string]time.Duration `env:"TIMEOUTS"`
+		Backoff  []*time.Duration         `env:"BACKOFF"`
+	}
+
+	env := MapLookuper{
+		"TIMEOUT":  "1h30m",
+		"TIMEOUTS": "read=5s,write=10s",
+		"BACKOFF":  "100ms,1s",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.Timeout != 90*time.Minute {
+		t.Errorf("failed parsing time.Duration; got %v", cfg.Timeout)
+	}
+	if cfg.Interval == nil || *cfg.Interval != time.Minute {
+		t.Errorf("failed parsing *time.Duration; got %v", cfg.Interval)
+	}
+
+	expected := map[string]time.Duration{"read": 5 * time.Second, "write": 10 * time.Second}
+	if !reflect.DeepEqual(cfg.Timeouts, expected) {
+		t.Errorf("failed parsing map of durations; expected %v, got %v", expected, cfg.Timeouts)
+	}
+
+	if len(cfg.Backoff) != 2 || cfg.Backoff[0] == nil || *cfg.Backoff[0] != 100*time.Millisecond ||
+		cfg.Backoff[1] == nil || *cfg.Backoff[1] != time.Second {
+		t.Errorf("failed parsing slice of duration pointers; got %v", cfg.Backoff)
+	}
+
+	env["TIMEOUTS"] = "read=soon"
+	if err := Parse(&cfg, WithLookuper(env)); err == nil {
+		t.Error("expected an error parsing an invalid duration in a map")
+	}
+}
+
+// version stands in for a type from another package that we can't add
+// methods to
+type version struct {
+	Major, Minor int
+}
+
+func TestTypeParser(t *testing.T) {
+	type config struct {
+		Version version  `env:"PARSER_VERSION"`
+		Min     *version `env:"PARSER_MIN" default:"1.0"`
+	}
+
+	parseVersion := func(s string) (version, error) {
+		var v version
+		if _, err := fmt.Sscanf(s, "%d.%d", &v.Major, &v.Minor); err != nil {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		return v, nil
+	}
+
+	opts := []Option{
+		WithTypeParser(reflect.TypeOf(version{}), func(s string) (interface{}, error) {
+			return parseVersion(s)
+		}),
+		WithTypeParser(reflect.TypeOf(&version{}), func(s string) (interface{}, error) {
+			v, err := parseVersion(s)
+			return &v, err
+		}),
+	}
+
+	var cfg config
+	if err := Parse(&cfg, append(opts, WithLookuper(MapLookuper{"PARSER_VERSION": "2.3"}))...); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Version != (version{2, 3}) {
+		t.Errorf("failed parsing with a type parser; got %#v", cfg.Version)
+	}
+	if cfg.Min == nil || *cfg.Min != (version{1, 0}) {
+		t.Errorf("failed parsing default with a type parser; got %#v", cfg.Min)
+	}
+
+	err := Parse(&cfg, append(opts, WithLookuper(MapLookuper{"PARSER_VERSION": "two"}))...)
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) || invalid.Name != "PARSER_VERSION" {
+		t.Errorf("expected an ErrorInvalidValue naming PARSER_VERSION; got %v", err)
+	}
+
+	mismatched := WithTypeParser(reflect.TypeOf(version{}), func(s string) (interface{}, error) {
+		return s, nil
+	})
+	var single struct {
+		Version version `env:"PARSER_VERSION"`
+	}
+	err = Parse(&single, WithLookuper(MapLookuper{"PARSER_VERSION": "2.3"}), mismatched)
+	if err == nil || !strings.Contains(err.Error(), "returned a string") {
+		t.Errorf("expected an error when a type parser returns the wrong type; got %v", err)
+	}
+}
+
+func TestStructMaps(t *testing.T) {
+	type server struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	type config struct {
+		Servers map[string]server `env:"SERVERS"`
+	}
+
+	env := MapLookuper{"SERVERS": `{"a": {"host": "x", "port": 80}, "b": {"host": "y"}}`}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := map[string]server{"a": {"x", 80}, "b": {"y", 0}}
+	if !reflect.DeepEqual(cfg.Servers, expected) {
+		t.Errorf("failed parsing map of structs; expected %#v, got %#v", expected, cfg.Servers)
+	}
+
+	env["SERVERS"] = `{"a": "x"}`
+	err := Parse(&cfg, WithLookuper(env))
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) || invalid.Name != "SERVERS" {
+		t.Errorf("expected an ErrorInvalidValue naming SERVERS; got %v", err)
+	}
+}
+
+func TestStructSlices(t *testing.T) {
+	type server struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	type config struct {
+		Servers []server `env:"SERVERS"`
+	}
+
+	env := MapLookuper{"SERVERS": `[{"host": "a", "port": 80}, {"host": "b"}]`}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := []server{{"a", 80}, {"b", 0}}
+	if !reflect.DeepEqual(cfg.Servers, expected) {
+		t.Errorf("failed parsing slice of structs; expected %#v, got %#v", expected, cfg.Servers)
+	}
+
+	env["SERVERS"] = `{"host": "a"}`
+	err := Parse(&cfg, WithLookuper(env))
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) || invalid.Name != "SERVERS" {
+		t.Errorf("expected an ErrorInvalidValue naming SERVERS; got %v", err)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	type config struct {
+		Name    string `env:"LENIENT_NAME"`
+		Workers int    `env:"LENIENT_WORKERS" default:"4"`
+		Port    int    `env:"LENIENT_PORT"`
+		Token   string `env:"LENIENT_TOKEN,required"`
+		Debug   bool   `env:"LENIENT_DEBUG"`
+	}
+
+	env := MapLookuper{
+		"LENIENT_NAME":    "Jane",
+		"LENIENT_WORKERS": "lots",
+		"LENIENT_PORT":    "high",
+		"LENIENT_DEBUG":   "true",
+	}
+
+	var cfg config
+	errs := ParseLenient(&cfg, WithLookuper(env))
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors; got %v", errs)
+	}
+
+	var invalid *ErrorInvalidValue
+	if !errors.As(errs[0], &invalid) || invalid.Name != "LENIENT_WORKERS" {
+		t.Errorf("expected an ErrorInvalidValue for LENIENT_WORKERS; got %v", errs[0])
+	}
+	if !errors.Is(errs[2], ErrorMissingRequired) {
+		t.Errorf("expected a missing required error for LENIENT_TOKEN; got %v", errs[2])
+	}
+
+	expected := config{Name: "Jane", Workers: 4, Debug: true}
+	if cfg != expected {
+		t.Errorf("expected other fields to be populated; expected %#v, got %#v", expected, cfg)
+	}
+
+	env["LENIENT_WORKERS"] = "8"
+	env["LENIENT_PORT"] = "8000"
+	env["LENIENT_TOKEN"] = "xyz"
+	if errs := ParseLenient(&cfg, WithLookuper(env)); errs != nil {
+		t.Errorf("expected no errors; got %v", errs)
+	}
+}
+
+func TestInvalidPointerValues(t *testing.T) {
+	type boolConfig struct {
+		A bool `env:"POINTER_A" default:"notbool"`
+	}
+	type boolPointerConfig struct {
+		A *bool `env:"POINTER_A" default:"notbool"`
+	}
+	type intConfig struct {
+		A int `env:"POINTER_A"`
+	}
+	type intPointerConfig struct {
+		A *int `env:"POINTER_A"`
+	}
+
+	tests := []struct {
+		value   string
+		cfg     interface{}
+		pointer interface{}
+	}{
+		{"", &boolConfig{}, &boolPointerConfig{}},
+		{"lots", &intConfig{}, &intPointerConfig{}},
+	}
+
+	for _, test := range tests {
+		env := MapLookuper{"POINTER_A": test.value}
+		expected := Parse(test.cfg, WithLookuper(env))
+		err := Parse(test.pointer, WithLookuper(env))
+
+		var invalid *ErrorInvalidValue
+		if !errors.As(err, &invalid) || invalid.Name != "POINTER_A" {
+			t.Errorf("expected an ErrorInvalidValue naming POINTER_A for %T; got %v", test.pointer, err)
+			continue
+		}
+		if expected == nil || err.Error() != expected.Error() {
+			t.Errorf("expected %T to fail like %T; expected %v, got %v", test.pointer, test.cfg, expected, err)
+		}
+	}
+
+	// Empty values give pointers to zero values
+	var cfg struct {
+		A *int64 `env:"POINTER_A"`
+	}
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"POINTER_A": ""})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+	}
+	if cfg.A == nil || *cfg.A != 0 {
+		t.Errorf("expected empty *int64 to point at 0; got %#v", cfg.A)
+	}
+}
+
+func TestUnsupportedType(t *testing.T) {
+	type config struct {
+		Weights map[int]int `env:"WEIGHTS"`
+	}
+
+	var cfg config
+	err := Parse(&cfg, WithLookuper(MapLookuper{}))
+
+	var unsupported *ErrorUnsupportedType
+	if !errors.As(err, &unsupported) {
+		t.Errorf("expected an ErrorUnsupportedType; got %v", err)
+		return
+	}
+
+	expected := "unsupported type map[int]int for field Weights (env WEIGHTS)"
+	if err.Error() != expected {
+		t.Errorf("unexpected error message; expected %#v, got %#v", expected, err.Error())
+	}
+}
+
+func TestValidateStruct(t *testing.T) {
+	type nested struct {
+		Ch chan int `env:"CH"`
+	}
+	type config struct {
+		Name    string      `env:"NAME"`
+		Weights map[int]int `env:"WEIGHTS"`
+		Nested  nested
+	}
+
+	err := ValidateStruct(config{})
+
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 2 {
+		t.Fatalf("expected an ErrorList of two errors; got %v", err)
+	}
+	for i, name := range []string{"WEIGHTS", "CH"} {
+		var unsupported *ErrorUnsupportedType
+		if !errors.As(list[i], &unsupported) || unsupported.Name != name {
+			t.Errorf("expected an ErrorUnsupportedType for %s; got %v", name, list[i])
+		}
+	}
+
+	// With WithValidateTypes nothing is set before the error is returned
+	var cfg config
+	err = Parse(&cfg, WithLookuper(MapLookuper{"NAME": "Jane"}), WithValidateTypes())
+	if !errors.As(err, &list) || len(list) != 2 {
+		t.Errorf("expected an ErrorList of two errors; got %v", err)
+	}
+	if cfg.Name != "" {
+		t.Errorf("expected no fields to be set; got %#v", cfg.Name)
+	}
+
+	type (
+		level   string
+		enabled bool
+		count   int
+		size    int64
+	)
+	type namedConfig struct {
+		Level   *level   `env:"LEVEL"`
+		Enabled *enabled `env:"ENABLED"`
+		Count   *count   `env:"COUNT"`
+		Size    *size    `env:"SIZE"`
+	}
+
+	if err := ValidateStruct(namedConfig{}); err != nil {
+		t.Errorf("expected pointers to named types to be supported; got %v", err)
+	}
+
+	env := MapLookuper{"LEVEL": "debug", "ENABLED": "true", "COUNT": "3", "SIZE": "4"}
+	var named namedConfig
+	if err := Parse(&named, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if named.Level == nil || *named.Level != "debug" ||
+		named.Enabled == nil || !*named.Enabled ||
+		named.Count == nil || *named.Count != 3 ||
+		named.Size == nil || *named.Size != 4 {
+		t.Errorf("failed parsing pointers to named types; got %#v", named)
+	}
+}
+
+func TestUniqueNames(t *testing.T) {
+	type nested struct {
+		Host string `env:"HOST"`
+	}
+	type config struct {
+		Host    string `env:"HOST"`
+		Port    int    `env:"PORT"`
+		Workers int    `env:"PORT"`
+		Nested  nested
+	}
+
+	env := MapLookuper{"HOST": "localhost", "PORT": "8000"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("expected duplicate names to be allowed by default; got %v", err)
+	}
+
+	cfg = config{}
+	err := Parse(&cfg, WithLookuper(env), WithUniqueNames())
+	expected := "HOST is used by more than one field: Host, Nested.Host; PORT is used by more than one field: Port, Workers"
+	if err == nil || err.Error() != expected {
+		t.Errorf("unexpected error; expected %#v, got %v", expected, err)
+	}
+	if cfg.Port != 0 {
+		t.Errorf("expected nothing to be parsed; got %#v", cfg)
+	}
+
+	var dup *ErrorDuplicateName
+	if err := ValidateStruct(cfg, WithUniqueNames()); !errors.As(err, &dup) || dup.Name != "HOST" {
+		t.Errorf("expected ValidateStruct to report an ErrorDuplicateName; got %v", err)
+	}
+}
+
+func TestSecretRedaction(t *testing.T) {
+	type config struct {
+		Pin int `env:"PIN" secret:"true"`
+	}
+
+	secret := "hunter2"
+	os.Setenv("PIN", secret)
+
+	var cfg config
+	err := Parse(&cfg)
+	if err == nil {
+		t.Error("expected an error parsing an invalid int")
+		return
+	}
+	if strings.Contains(err.Error(), secret) {
+		t.Errorf("expected the secret value to be redacted; got %v", err)
+	}
+	if !strings.Contains(err.Error(), "PIN") {
+		t.Errorf("expected error to name the env var; got %v", err)
+	}
+
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue; got %v", err)
+	} else if invalid.Value == secret {
+		t.Errorf("expected the secret value to be redacted from the error struct")
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if strings.Contains(e.Error(), secret) {
+			t.Errorf("expected the secret value to be redacted from wrapped errors; got %v", e)
+		}
+	}
+	var num *strconv.NumError
+	if errors.As(err, &num) {
+		t.Errorf("expected the *strconv.NumError holding the secret not to be reachable; got %#v", num.Num)
+	}
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("expected the underlying error to still match with errors.Is; got %v", err)
+	}
+
+	type listConfig struct {
+		Pins []int `env:"PINS" secret:"true"`
+	}
+
+	var list listConfig
+	err = Parse(&list, WithLookuper(MapLookuper{"PINS": "1234,hunter2"}))
+	if err == nil {
+		t.Error("expected an error parsing an invalid []int element")
+		return
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if strings.Contains(e.Error(), secret) || strings.Contains(fmt.Sprintf("%+v", e), secret) {
+			t.Errorf("expected the secret element to be redacted from wrapped errors; got %v", e)
+		}
+	}
+}
+
+func TestParseMultiple(t *testing.T) {
+	type server struct {
+		Port int `env:"PORT" default:"8000"`
+	}
+	type database struct {
+		URL string `env:"DATABASE_URL,required"`
+	}
+	type cache struct {
+		Host string `env:"CACHE_HOST"`
+	}
+
+	env := MapLookuper{"CACHE_HOST": "localhost"}
+
+	var (
+		s server
+		d database
+		c cache
+	)
+	err := ParseMultiple(&s, &d, &c, WithLookuper(env))
+
+	var required *ErrorEnvVarRequired
+	if !errors.As(err, &required) {
+		t.Errorf("expected an ErrorEnvVarRequired; got %v", err)
+	}
+	if c.Host != "" {
+		t.Errorf("expected parsing to stop at the first error; got %#v", c.Host)
+	}
+
+	s, d, c = server{}, database{}, cache{}
+	err = ParseMultiple(&s, &d, &c, WithLookuper(env), WithCollectErrors())
+
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Errorf("expected an ErrorList; got %v", err)
+	} else if len(list) != 1 || !errors.As(list[0], &required) || required.Name != "DATABASE_URL" {
+		t.Errorf("expected a single missing DATABASE_URL error; got %v", list)
+	}
+	if s.Port != 8000 {
+		t.Errorf("failed parsing server config; expected %#v, got %#v", 8000, s.Port)
+	}
+	if c.Host != "localhost" {
+		t.Errorf("failed parsing cache config; expected %#v, got %#v", "localhost", c.Host)
+	}
+}
+
+func TestCollectErrors(t *testing.T) {
+	type config struct {
+		A int    `env:"A"`
+		B string `env:"B,required"`
+		C bool   `env:"C"`
+		D string `env:"D"`
+	}
+
+	env := MapLookuper{"A": "xxx", "C": "maybe", "D": "yyy"}
+
+	var cfg config
+	err := Parse(&cfg, WithLookuper(env), WithCollectErrors())
+
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Errorf("expected an ErrorList; got %v", err)
+		return
+	}
+	if len(list) != 3 {
+		t.Errorf("expected 3 errors; got %d: %v", len(list), list)
+	}
+	if cfg.D != "yyy" {
+		t.Errorf("expected parsing to continue after errors; expected %#v, got %#v", "yyy", cfg.D)
+	}
+}
+
+func TestRespectExistingValues(t *testing.T) {
+	type config struct {
+		A int    `env:"A"`
+		B string `env:"B" default:"xxx"`
+		C string `env:"C"`
+	}
+
+	env := MapLookuper{"C": "zzz"}
+
+	cfg := config{A: 16, B: "yyy", C: "yyy"}
+	if err := Parse(&cfg, WithLookuper(env), WithRespectExistingValues()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != 16 {
+		t.Errorf("expected pre-set value to survive; expected %#v, got %#v", 16, cfg.A)
+	}
+	if cfg.B != "xxx" {
+		t.Errorf("expected default tag to take precedence; expected %#v, got %#v", "xxx", cfg.B)
+	}
+	if cfg.C != "zzz" {
+		t.Errorf("expected env var to take precedence; expected %#v, got %#v", "zzz", cfg.C)
+	}
+
+	cfg = config{A: 16}
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.A != 0 {
+		t.Errorf("expected pre-set value to be overwritten by default; got %#v", cfg.A)
+	}
+}
+
+func TestNamedSeparators(t *testing.T) {
+	type config struct {
+		A []string `env:"A" sep:"newline"`
+		B []string `env:"B" sep:"tab"`
+		C []string `env:"C" sep:"space"`
+	}
+
+	expected := []string{"x,1", "y,2"}
+
+	env := MapLookuper{
+		"A": "x,1\ny,2",
+		"B": "x,1\ty,2",
+		"C": "x,1 y,2",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if !reflect.DeepEqual(cfg.A, expected) {
+		t.Errorf("failed splitting on newline; expected %#v, got %#v", expected, cfg.A)
+	}
+	if !reflect.DeepEqual(cfg.B, expected) {
+		t.Errorf("failed splitting on tab; expected %#v, got %#v", expected, cfg.B)
+	}
+	if !reflect.DeepEqual(cfg.C, expected) {
+		t.Errorf("failed splitting on space; expected %#v, got %#v", expected, cfg.C)
+	}
+}
+
+func TestCSVLists(t *testing.T) {
+	type config struct {
+		A []string  `env:"A"`
+		B [2]string `env:"B" sep:";"`
+		C []string  `env:"C" sep:"newline"`
+	}
+
+	env := MapLookuper{"A": `x,"y,z", w`, "B": `"a;b";c`, "C": "\"1\"\n2"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithCSVLists()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if expected := []string{"x", "y,z", "w"}; !reflect.DeepEqual(cfg.A, expected) {
+		t.Errorf("failed parsing CSV list; expected %#v, got %#v", expected, cfg.A)
+	}
+	if expected := [2]string{"a;b", "c"}; cfg.B != expected {
+		t.Errorf("failed parsing CSV array; expected %#v, got %#v", expected, cfg.B)
+	}
+	if expected := []string{`"1"`, "2"}; !reflect.DeepEqual(cfg.C, expected) {
+		t.Errorf("expected newline-separated list to be split as usual; got %#v", cfg.C)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"A": `x,"y`}), WithCSVLists()); err == nil {
+		t.Error("expected an error parsing an unclosed quote")
+	}
+}
+
+func TestNilVersusEmptySlices(t *testing.T) {
+	type config struct {
+		A []byte   `env:"A"`
+		B []string `env:"B"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.A != nil {
+		t.Errorf("expected unset []byte to be nil; got %#v", cfg.A)
+	}
+	if cfg.B != nil {
+		t.Errorf("expected unset []string to be nil; got %#v", cfg.B)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"A": "", "B": ""})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.A == nil || len(cfg.A) != 0 {
+		t.Errorf("expected empty []byte to be empty and non-nil; got %#v", cfg.A)
+	}
+	if cfg.B == nil || len(cfg.B) != 0 {
+		t.Errorf("expected empty []string to be empty and non-nil; got %#v", cfg.B)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	type config struct {
+		A string `env:"A,required"`
+		B int    `env:"B,required"`
+		C bool   `env:"C,required"`
+		D string `env:"D,required"`
+		E string `env:"E"`
+	}
+
+	var cfg config
+	missing, err := Validate(&cfg, WithLookuper(MapLookuper{"D": "ddd", "E": "eee"}))
+	if err != nil {
+		t.Errorf("error while validating: %v", err)
+		return
+	}
+
+	expected := []string{"A", "B", "C"}
+	if !reflect.DeepEqual(missing, expected) {
+		t.Errorf("failed reporting missing vars; expected %#v, got %#v", expected, missing)
+	}
+	if cfg.D != "" || cfg.E != "" {
+		t.Errorf("expected the struct not to be populated; got %#v", cfg)
+	}
+
+	missing, err = Validate(cfg, WithLookuper(MapLookuper{"D": "ddd", "E": "eee"}))
+	if err != nil {
+		t.Errorf("error while validating a struct value: %v", err)
+		return
+	}
+	if !reflect.DeepEqual(missing, expected) {
+		t.Errorf("failed reporting missing vars for a struct value; expected %#v, got %#v", expected, missing)
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	type config struct {
+		A string `env:"A" transform:"trim,lower"`
+		B string `env:"B" transform:"stripScheme"`
+		C string `env:"C" transform:"upper" default:"ccc"`
+	}
+
+	env := MapLookuper{
+		"A": "  Example.COM ",
+		"B": "https://example.com",
+	}
+
+	stripScheme := func(s string) string {
+		if i := strings.Index(s, "://"); i >= 0 {
+			return s[i+3:]
+		}
+		return s
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithTransforms(map[string]func(string) string{
+		"stripScheme": stripScheme,
+	})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != "example.com" {
+		t.Errorf("failed applying built-in transforms; expected %#v, got %#v", "example.com", cfg.A)
+	}
+	if cfg.B != "example.com" {
+		t.Errorf("failed applying custom transform; expected %#v, got %#v", "example.com", cfg.B)
+	}
+	if cfg.C != "CCC" {
+		t.Errorf("failed transforming default; expected %#v, got %#v", "CCC", cfg.C)
+	}
+
+	if err := Parse(&cfg, WithLookuper(env)); err == nil {
+		t.Error("expected an error using an unknown transform")
+	}
+}
+
+func TestNestedStructs(t *testing.T) {
+	type database struct {
+		Host string `env:"DB_HOST"`
+		Port int    `env:"DB_PORT" default:"5432"`
+	}
+	type cache struct {
+		Host string `env:"CACHE_HOST"`
+	}
+	type node struct {
+		Name string `env:"NODE_NAME"`
+		Next *node
+	}
+	type config struct {
+		Name     string `env:"NAME"`
+		Database *database
+		Cache    *cache
+		Node     node
+	}
+
+	env := MapLookuper{
+		"NAME":      "app",
+		"DB_HOST":   "localhost",
+		"NODE_NAME": "a",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.Database == nil {
+		t.Error("expected nested pointer struct to be allocated")
+	} else if cfg.Database.Host != "localhost" || cfg.Database.Port != 5432 {
+		t.Errorf("failed parsing nested pointer struct; got %#v", cfg.Database)
+	}
+	if cfg.Cache != nil {
+		t.Errorf("expected unset nested pointer struct to be left nil; got %#v", cfg.Cache)
+	}
+	if cfg.Node.Name != "a" {
+		t.Errorf("failed parsing nested struct; expected %#v, got %#v", "a", cfg.Node.Name)
+	}
+
+	cfg = config{}
+	if err := Parse(&cfg, WithLookuper(env), WithAllocateNilStructs()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Cache == nil {
+		t.Error("expected unset nested pointer struct to be allocated")
+	}
+}
+
+func TestNestedFieldPaths(t *testing.T) {
+	type primary struct {
+		Port int    `env:"DB_PORT"`
+		Name string `env:"DB_NAME"`
+		bad  string `env:"DB_BAD"`
+	}
+	type database struct {
+		Primary primary
+	}
+	type config struct {
+		Database database
+	}
+
+	var cfg config
+	err := Parse(&cfg, WithLookuper(MapLookuper{"DB_PORT": "http"}))
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue; got %v", err)
+	} else if invalid.FieldName != "Database.Primary.Port" || !strings.Contains(err.Error(), "Database.Primary.Port") {
+		t.Errorf("expected the error to hold the full path to the field; got %v", err)
+	}
+
+	err = Parse(&cfg, WithLookuper(MapLookuper{"DB_PORT": "1"}))
+	var unsettable *ErrorUnsettable
+	if !errors.As(err, &unsettable) || unsettable.FieldName != "Database.Primary.bad" {
+		t.Errorf("expected an ErrorUnsettable for Database.Primary.bad; got %v", err)
+	}
+}
+
+func TestRequiredDescription(t *testing.T) {
+	type config struct {
+		Name string `env:"NAME,required" desc:"the display name shown to users"`
+	}
+
+	var cfg config
+	err := Parse(&cfg, WithLookuper(MapLookuper{}))
+
+	var required *ErrorEnvVarRequired
+	if !errors.As(err, &required) {
+		t.Errorf("expected an ErrorEnvVarRequired; got %v", err)
+		return
+	}
+	if required.Desc != "the display name shown to users" {
+		t.Errorf("failed reading description; got %#v", required.Desc)
+	}
+
+	expected := "NAME is required: the display name shown to users"
+	if err.Error() != expected {
+		t.Errorf("unexpected error message; expected %#v, got %#v", expected, err.Error())
+	}
+}
+
+func TestInterfaceInference(t *testing.T) {
+	type config struct {
+		A interface{} `env:"A"`
+		B interface{} `env:"B"`
+		C interface{} `env:"C"`
+		D interface{} `env:"D"`
+	}
+
+	env := MapLookuper{
+		"A": "42",
+		"B": "true",
+		"C": "xxx",
+		"D": "0.5",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err == nil {
+		t.Error("expected interface{} fields to be unsupported without WithInterfaceInference")
+	}
+
+	if err := Parse(&cfg, WithLookuper(env), WithInterfaceInference()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != 42 {
+		t.Errorf("failed inferring int; expected %#v, got %#v", 42, cfg.A)
+	}
+	if cfg.B != true {
+		t.Errorf("failed inferring bool; expected %#v, got %#v", true, cfg.B)
+	}
+	if cfg.C != "xxx" {
+		t.Errorf("failed inferring string; expected %#v, got %#v", "xxx", cfg.C)
+	}
+	if cfg.D != 0.5 {
+		t.Errorf("failed inferring float64; expected %#v, got %#v", 0.5, cfg.D)
+	}
+
+	type mapConfig struct {
+		M map[string]interface{} `env:"M"`
+	}
+
+	var m mapConfig
+	env["M"] = "a=1,b=true,c=xxx"
+	if err := Parse(&m, WithLookuper(env)); err == nil {
+		t.Error("expected map[string]interface{} fields to be unsupported without WithInterfaceInference")
+	}
+	if err := Parse(&m, WithLookuper(env), WithInterfaceInference()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if expected := map[string]interface{}{"a": 1, "b": true, "c": "xxx"}; !reflect.DeepEqual(m.M, expected) {
+		t.Errorf("failed inferring map values; expected %#v, got %#v", expected, m.M)
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	type config struct {
+		Workers int `env:"WORKERS" default:"notanint"`
+	}
+
+	env := MapLookuper{"WORKERS": "4"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("expected the unused bad default to be ignored; got %v", err)
+	}
+	if cfg.Workers != 4 {
+		t.Errorf("failed parsing int; expected %#v, got %#v", 4, cfg.Workers)
+	}
+
+	err := Parse(&cfg, WithLookuper(env), WithValidateDefaults())
+
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue for the bad default; got %v", err)
+	} else if invalid.Value != "notanint" {
+		t.Errorf("expected the error to hold the bad default; got %#v", invalid.Value)
+	}
+
+	type refConfig struct {
+		A int `env:"A" default:"1"`
+		B int `env:"B" default:"{A}"`
+		C int `env:"C" default:"${N:-2}"`
+	}
+
+	var ref refConfig
+	if err := Parse(&ref, WithLookuper(MapLookuper{}), WithValidateDefaults(), WithExpandDefaults()); err != nil {
+		t.Errorf("expected defaults with references to be skipped; got %v", err)
+	}
+	if ref.B != 1 || ref.C != 2 {
+		t.Errorf("failed resolving referenced defaults; expected 1 and 2, got %#v and %#v", ref.B, ref.C)
+	}
+}
+
+func TestParseMaps(t *testing.T) {
+	type config struct {
+		A map[string]int    `env:"A"`
+		B map[string]bool   `env:"B" sep:";"`
+		C map[string]string `env:"C"`
+		D map[string]int    `env:"D"`
+	}
+
+	a := map[string]int{"a": 1, "b": 2}
+	b := map[string]bool{"x": true, "y": false}
+	c := map[string]string{"host": "example.com", "query": "q=1"}
+
+	env := MapLookuper{
+		"A": "a=1, b=2",
+		"B": "x=true;y=false",
+		"C": "host=example.com,query=q=1",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if !reflect.DeepEqual(cfg.A, a) {
+		t.Errorf("failed parsing map[string]int; expected %#v, got %#v", a, cfg.A)
+	}
+	if !reflect.DeepEqual(cfg.B, b) {
+		t.Errorf("failed parsing map[string]bool; expected %#v, got %#v", b, cfg.B)
+	}
+	if !reflect.DeepEqual(cfg.C, c) {
+		t.Errorf("failed parsing map[string]string; expected %#v, got %#v", c, cfg.C)
+	}
+	if cfg.D != nil {
+		t.Errorf("expected unset map to be nil; got %#v", cfg.D)
+	}
+}
+
+func TestParseMapsInvalidEntry(t *testing.T) {
+	type ints struct {
+		Limits map[string]int `env:"LIMITS"`
+	}
+	type bools struct {
+		Flags map[string]bool `env:"FLAGS"`
+	}
+
+	var i ints
+	if err := Parse(&i, WithLookuper(MapLookuper{"LIMITS": "a=1,b"})); err == nil {
+		t.Error("expected an error parsing a malformed map entry")
+	} else if !strings.Contains(err.Error(), "LIMITS") {
+		t.Errorf("expected error to name the env var; got %v", err)
+	}
+
+	var b bools
+	if err := Parse(&b, WithLookuper(MapLookuper{"FLAGS": "a=true,beta=maybe"})); err == nil {
+		t.Error("expected an error parsing an invalid map value")
+	} else if !strings.Contains(err.Error(), "key beta") {
+		t.Errorf("expected error to name the key; got %v", err)
+	}
+}
+
+func TestRequiredIf(t *testing.T) {
+	type config struct {
+		DatabaseURL string `env:"DATABASE_URL,required_if=ENV:production"`
+		TLSKey      string `env:"TLS_KEY,required_if=TLS_CERT"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"ENV": "dev"})); err != nil {
+		t.Errorf("expected DATABASE_URL not to be required in dev; got %v", err)
+	}
+
+	err := Parse(&cfg, WithLookuper(MapLookuper{"ENV": "production"}))
+	var required *ErrorEnvVarRequired
+	if !errors.As(err, &required) || required.Name != "DATABASE_URL" {
+		t.Errorf("expected DATABASE_URL to be required in production; got %v", err)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"ENV": "production", "DATABASE_URL": "postgres://"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+	}
+
+	err = Parse(&cfg, WithLookuper(MapLookuper{"TLS_CERT": "cert.pem"}))
+	if !errors.As(err, &required) || required.Name != "TLS_KEY" {
+		t.Errorf("expected TLS_KEY to be required when TLS_CERT is set; got %v", err)
+	}
+}
+
+func TestNullTokens(t *testing.T) {
+	type config struct {
+		C *int    `env:"C"`
+		D string  `env:"D"`
+		E *string `env:"E"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"C": "null", "D": "null", "E": "nil"}), WithNullTokens("null", "nil")); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.C != nil {
+		t.Errorf("expected *int to be nil; got %#v", *cfg.C)
+	}
+	if cfg.D != "null" {
+		t.Errorf("expected null tokens not to apply to non-pointers; got %#v", cfg.D)
+	}
+	if cfg.E != nil {
+		t.Errorf("expected *string to be nil; got %#v", *cfg.E)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"C": "5"}), WithNullTokens("null", "nil")); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.C == nil {
+		t.Errorf("failed parsing *int; expected %#v, got nil", 5)
+	} else if *cfg.C != 5 {
+		t.Errorf("failed parsing *int; expected %#v, got %#v", 5, *cfg.C)
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	type config struct {
+		A string  `env:"A"`
+		B string  `env:"B"`
+		C []byte  `env:"C"`
+		D *string `env:"D"`
+		E int     `env:"E"`
+	}
+
+	env := MapLookuper{
+		"A": `"line one\nline two"`,
+		"B": `'single \n quoted'`,
+		"C": "unquoted",
+		"D": `"pointer"`,
+		"E": "16",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithUnquote()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != "line one\nline two" {
+		t.Errorf("failed unquoting double quoted value; got %#v", cfg.A)
+	}
+	if cfg.B != `single \n quoted` {
+		t.Errorf("failed unquoting single quoted value; got %#v", cfg.B)
+	}
+	if string(cfg.C) != "unquoted" {
+		t.Errorf("expected unquoted value to be untouched; got %#v", string(cfg.C))
+	}
+	if cfg.D == nil || *cfg.D != "pointer" {
+		t.Errorf("failed unquoting *string; got %#v", cfg.D)
+	}
+
+	for _, val := range []string{`"unbalanced`, `unbalanced'`, `"mismatched'`, `"`} {
+		err := Parse(&cfg, WithLookuper(MapLookuper{"A": val}), WithUnquote())
+		var invalid *ErrorInvalidValue
+		if !errors.As(err, &invalid) {
+			t.Errorf("expected an ErrorInvalidValue unquoting %#v; got %v", val, err)
+		} else if invalid.Value != val {
+			t.Errorf("expected the error to hold the bad value; expected %#v, got %#v", val, invalid.Value)
+		}
+	}
+}
+
+func TestDigitSeparators(t *testing.T) {
+	type config struct {
+		A int     `env:"A"`
+		B int64   `env:"B"`
+		C float64 `env:"C"`
+		D *int    `env:"D"`
+	}
+
+	env := MapLookuper{
+		"A": "10_000",
+		"B": "10_000_000",
+		"C": "1_000.5",
+		"D": "1_0",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != 10000 {
+		t.Errorf("failed parsing int with underscores; expected %#v, got %#v", 10000, cfg.A)
+	}
+	if cfg.B != 10000000 {
+		t.Errorf("failed parsing int64 with underscores; expected %#v, got %#v", 10000000, cfg.B)
+	}
+	if cfg.C != 1000.5 {
+		t.Errorf("failed parsing float64 with underscores; expected %#v, got %#v", 1000.5, cfg.C)
+	}
+	if cfg.D == nil || *cfg.D != 10 {
+		t.Errorf("failed parsing *int with underscores; got %#v", cfg.D)
+	}
+
+	for _, val := range []string{"_10", "10_", "1__0", "-_1"} {
+		if err := Parse(&cfg, WithLookuper(MapLookuper{"A": val})); err == nil {
+			t.Errorf("expected an error parsing %#v", val)
+		}
+	}
+}
+
+func TestErrorMissingRequired(t *testing.T) {
+	type config struct {
+		A string `env:"A,required"`
+		B string `env:"B,required"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{})); !errors.Is(err, ErrorMissingRequired) {
+		t.Errorf("expected error to match ErrorMissingRequired; got %v", err)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{}), WithCollectErrors()); !errors.Is(err, ErrorMissingRequired) {
+		t.Errorf("expected collected errors to match ErrorMissingRequired; got %v", err)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"A": "a", "B": "b"})); errors.Is(err, ErrorMissingRequired) {
+		t.Errorf("expected no ErrorMissingRequired; got %v", err)
+	}
+}
+
+func TestParseArrays(t *testing.T) {
+	type config struct {
+		RGB [3]int `env:"RGB"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"RGB": "255, 128, 0"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := [3]int{255, 128, 0}
+	if cfg.RGB != expected {
+		t.Errorf("failed parsing [3]int; expected %#v, got %#v", expected, cfg.RGB)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"RGB": "255,128"})); err == nil {
+		t.Error("expected an error parsing a mismatched number of elements")
+	} else if !strings.Contains(err.Error(), "expected 3 elements") {
+		t.Errorf("expected error to describe the mismatch; got %v", err)
+	}
+}
+
+func TestSkipUnsupported(t *testing.T) {
+	type config struct {
+		A string           `env:"A"`
+		B map[int]int      `env:"B"`
+		C int              `env:"C"`
+		D chan string      `env:"D"`
+		E complex128       `env:"E"`
+		F map[string][]int `env:"F"`
+	}
+
+	env := MapLookuper{"A": "xxx", "B": "1=2", "C": "16", "E": "1+2i"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err == nil {
+		t.Error("expected an error parsing unsupported types by default")
+	}
+
+	cfg = config{}
+	if err := Parse(&cfg, WithLookuper(env), WithSkipUnsupported()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != "xxx" {
+		t.Errorf("failed parsing string; expected %#v, got %#v", "xxx", cfg.A)
+	}
+	if cfg.C != 16 {
+		t.Errorf("failed parsing int; expected %#v, got %#v", 16, cfg.C)
+	}
+	if cfg.B != nil || cfg.D != nil || cfg.E != 0 || cfg.F != nil {
+		t.Errorf("expected unsupported fields to be left at their zero values; got %#v", cfg)
+	}
+}
+
+func TestSQLNullTypes(t *testing.T) {
+	type config struct {
+		A sql.NullString  `env:"A"`
+		B sql.NullString  `env:"B"`
+		C sql.NullInt64   `env:"C"`
+		D sql.NullBool    `env:"D"`
+		E sql.NullFloat64 `env:"E" default:"0.5"`
+		F sql.NullInt64   `env:"F"`
+	}
+
+	env := MapLookuper{"A": "xxx", "C": "16", "D": "true"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if !cfg.A.Valid || cfg.A.String != "xxx" {
+		t.Errorf("failed parsing set sql.NullString; got %#v", cfg.A)
+	}
+	if cfg.B.Valid {
+		t.Errorf("expected unset sql.NullString to be invalid; got %#v", cfg.B)
+	}
+	if !cfg.C.Valid || cfg.C.Int64 != 16 {
+		t.Errorf("failed parsing sql.NullInt64; got %#v", cfg.C)
+	}
+	if !cfg.D.Valid || !cfg.D.Bool {
+		t.Errorf("failed parsing sql.NullBool; got %#v", cfg.D)
+	}
+	if !cfg.E.Valid || cfg.E.Float64 != 0.5 {
+		t.Errorf("failed parsing sql.NullFloat64 default; got %#v", cfg.E)
+	}
+	if cfg.F.Valid {
+		t.Errorf("expected unset sql.NullInt64 to be invalid; got %#v", cfg.F)
+	}
+}
+
+type afterParseConfig struct {
+	Host string `env:"HOST"`
+	Port int    `env:"PORT"`
+	Addr string
+}
+
+func (c *afterParseConfig) AfterParse() error {
+	if c.Port == 0 {
+		return errors.New("port must be set")
+	}
+	c.Addr = fmt.Sprintf("%s:%d", c.Host, c.Port)
+	return nil
+}
+
+func TestAfterParse(t *testing.T) {
+	var cfg afterParseConfig
+	err := Parse(&cfg, WithLookuper(MapLookuper{"HOST": "localhost", "PORT": "8000"}))
+	if err != nil {
+		t.Errorf("error while parsing: %v", err)
+	}
+	if cfg.Addr != "localhost:8000" {
+		t.Errorf("expected AfterParse to derive localhost:8000; got %q", cfg.Addr)
+	}
+
+	cfg = afterParseConfig{}
+	err = Parse(&cfg, WithLookuper(MapLookuper{"HOST": "localhost"}))
+	if err == nil || err.Error() != "port must be set" {
+		t.Errorf("expected error from AfterParse; got %v", err)
+	}
+}
+
+// rgb implements encoding.TextUnmarshaler, parsing colors like #ff8000
+type rgb struct {
+	R, G, B uint8
+}
+
+func (c *rgb) UnmarshalText(text []byte) error {
+	if _, err := fmt.Sscanf(string(text), "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+		return fmt.Errorf("invalid color %q", text)
+	}
+	return nil
+}
+
+func TestTextUnmarshaler(t *testing.T) {
+	type config struct {
+		Fg rgb  `env:"TEXT_FG"`
+		Bg *rgb `env:"TEXT_BG" default:"#000000"`
+		Hl *rgb `env:"TEXT_HL"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"TEXT_FG": "#ff8000"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Fg != (rgb{0xff, 0x80, 0x00}) {
+		t.Errorf("failed parsing TextUnmarshaler; got %#v", cfg.Fg)
+	}
+	if cfg.Bg == nil || *cfg.Bg != (rgb{}) {
+		t.Errorf("failed parsing pointer to TextUnmarshaler; got %#v", cfg.Bg)
+	}
+	if cfg.Hl != nil {
+		t.Errorf("expected unset pointer to TextUnmarshaler to be nil; got %#v", cfg.Hl)
+	}
+
+	err := Parse(&cfg, WithLookuper(MapLookuper{"TEXT_FG": "orange"}))
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) || invalid.Name != "TEXT_FG" {
+		t.Errorf("expected an ErrorInvalidValue naming TEXT_FG; got %v", err)
+	}
+}
+
+func TestUnsigned(t *testing.T) {
+	type config struct {
+		A uint   `env:"A"`
+		B uint64 `env:"B"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"A": "5", "B": "10_000"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.A != 5 || cfg.B != 10000 {
+		t.Errorf("failed parsing unsigned ints; got %#v", cfg)
+	}
+
+	err := Parse(&cfg, WithLookuper(MapLookuper{"A": "-5"}))
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue; got %v", err)
+		return
+	}
+	expected := `invalid value "-5" for A (field A): negative values aren't allowed for unsigned fields`
+	if err.Error() != expected {
+		t.Errorf("unexpected error message; expected %#v, got %#v", expected, err.Error())
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	type config struct {
+		Name     string `env:"NAME"`
+		Features string `env:"FEATURES"`
+	}
+
+	env := MapLookuper{"NAME": "Jane", "FEATURES": "a"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	env["NAME"] = "Joe"
+	env["FEATURES"] = "a,b"
+	if err := ParseFields(&cfg, []string{"Features"}, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing fields: %v", err)
+		return
+	}
+	if cfg.Features != "a,b" {
+		t.Errorf("expected Features to be re-parsed; got %#v", cfg.Features)
+	}
+	if cfg.Name != "Jane" {
+		t.Errorf("expected Name to be left alone; got %#v", cfg.Name)
+	}
+
+	var unknown *ErrorUnknownField
+	if err := ParseFields(&cfg, []string{"Nope"}, WithLookuper(env)); !errors.As(err, &unknown) {
+		t.Errorf("expected an ErrorUnknownField; got %v", err)
+	}
+}
+
+func TestDoublePointers(t *testing.T) {
+	type config struct {
+		A **int    `env:"A"`
+		B **string `env:"B"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"A": "42", "B": "hi"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.A == nil || *cfg.A == nil || **cfg.A != 42 {
+		t.Errorf("failed parsing **int; got %#v", cfg.A)
+	}
+	if cfg.B == nil || *cfg.B == nil || **cfg.B != "hi" {
+		t.Errorf("failed parsing **string; got %#v", cfg.B)
+	}
+
+	var invalid *ErrorInvalidValue
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"A": "nope"})); !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue; got %v", err)
+	}
+}
+
+func TestCombinedTagOptions(t *testing.T) {
+	type config struct {
+		A string `env:"A,required,trim"`
+		B string `env:"B,trim,required"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"A": "  a ", "B": "\tb\n"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.A != "a" || cfg.B != "b" {
+		t.Errorf("expected values to be trimmed; got %#v", cfg)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"A": "a"})); !errors.Is(err, ErrorMissingRequired) {
+		t.Errorf("expected B to be required; got %v", err)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	type config struct {
+		Cert []byte `env:"CERT_PATH" source:"file"`
+	}
+
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(path, []byte("-----BEGIN CERTIFICATE-----\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"CERT_PATH": path})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if string(cfg.Cert) != "-----BEGIN CERTIFICATE-----\n" {
+		t.Errorf("expected the file's contents; got %#v", string(cfg.Cert))
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	err := Parse(&cfg, WithLookuper(MapLookuper{"CERT_PATH": missing}))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a not-exist error; got %v", err)
+	} else if !strings.Contains(err.Error(), "CERT_PATH") || !strings.Contains(err.Error(), missing) {
+		t.Errorf("expected the error to mention the variable and path; got %v", err)
+	}
+}
+
+func TestURLEncoding(t *testing.T) {
+	type config struct {
+		Password string `env:"PASSWORD" encoding:"url"`
+		Token    []byte `env:"TOKEN" encoding:"url"`
+		Raw      string `env:"RAW"`
+	}
+
+	env := MapLookuper{"PASSWORD": "p%40ss", "TOKEN": "a%2Fb", "RAW": "p%40ss"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Password != "p@ss" {
+		t.Errorf("failed decoding string; expected %#v, got %#v", "p@ss", cfg.Password)
+	}
+	if string(cfg.Token) != "a/b" {
+		t.Errorf("failed decoding []byte; expected %#v, got %#v", "a/b", string(cfg.Token))
+	}
+	if cfg.Raw != "p%40ss" {
+		t.Errorf("expected untagged value to be left alone; got %#v", cfg.Raw)
+	}
+
+	var invalid *ErrorInvalidValue
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"PASSWORD": "%zz"})); !errors.As(err, &invalid) || invalid.Name != "PASSWORD" {
+		t.Errorf("expected an ErrorInvalidValue for PASSWORD; got %v", err)
+	}
+}
+
+func TestCustomErrorMessages(t *testing.T) {
+	type config struct {
+		Port int `env:"PORT,required" errmsg:"Set PORT to the port to listen on"`
+	}
+
+	var cfg config
+	err := Parse(&cfg, WithLookuper(MapLookuper{}))
+	var required *ErrorEnvVarRequired
+	if !errors.As(err, &required) {
+		t.Errorf("expected an ErrorEnvVarRequired; got %v", err)
+	} else if required.Message != "Set PORT to the port to listen on" || err.Error() != required.Message {
+		t.Errorf("expected the custom message; got %#v", err.Error())
+	}
+
+	err = Parse(&cfg, WithLookuper(MapLookuper{"PORT": "http"}))
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue; got %v", err)
+	} else if err.Error() != "Set PORT to the port to listen on" {
+		t.Errorf("expected the custom message; got %#v", err.Error())
+	}
+}
+
+// frame implements encoding.BinaryUnmarshaler, reading a version byte
+// followed by a payload
+type frame struct {
+	Version byte
+	Payload []byte
+}
+
+func (f *frame) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return errors.New("empty frame")
+	}
+	f.Version, f.Payload = data[0], data[1:]
+	return nil
+}
+
+func TestBinaryUnmarshaler(t *testing.T) {
+	type config struct {
+		A frame  `env:"FRAME_A" encoding:"base64"`
+		B *frame `env:"FRAME_B" encoding:"hex"`
+		C []byte `env:"FRAME_C" encoding:"base64"`
+	}
+
+	env := MapLookuper{
+		"FRAME_A": "AmhpIQ==", // \x02hi!
+		"FRAME_B": "0179",
+		"FRAME_C": "aGk=",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.A.Version != 2 || string(cfg.A.Payload) != "hi!" {
+		t.Errorf("failed unmarshalling base64 into frame; got %#v", cfg.A)
+	}
+	if cfg.B == nil || cfg.B.Version != 1 || string(cfg.B.Payload) != "y" {
+		t.Errorf("failed unmarshalling hex into *frame; got %#v", cfg.B)
+	}
+	if string(cfg.C) != "hi" {
+		t.Errorf("failed decoding base64 into []byte; got %#v", string(cfg.C))
+	}
+
+	var invalid *ErrorInvalidValue
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"FRAME_A": "!!"})); !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue for bad base64; got %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	type inner struct {
+		X string `env:"X"`
+	}
+	type config struct {
+		Host  string  `env:"HOST" default:"localhost"`
+		Port  int     `env:"PORT"`
+		Token *string `env:"TOKEN"`
+		Local string  `env:"-"`
+		Inner *inner
+	}
+
+	env := MapLookuper{"HOST": "example.com", "PORT": "8000", "TOKEN": "abc", "X": "x"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	cfg.Local = "kept"
+	if cfg.Inner == nil || cfg.Inner.X != "x" {
+		t.Errorf("expected the nested struct to be allocated; got %#v", cfg.Inner)
+		return
+	}
+
+	env["PORT"] = "9000"
+	delete(env, "HOST")
+	delete(env, "TOKEN")
+	delete(env, "X")
+
+	for i := 0; i < 2; i++ {
+		if err := Reload(&cfg, WithLookuper(env), WithRespectExistingValues()); err != nil {
+			t.Errorf("error while reloading: %v", err)
+			return
+		}
+		if cfg.Port != 9000 {
+			t.Errorf("expected the changed variable to be picked up; got %#v", cfg.Port)
+		}
+		if cfg.Host != "localhost" {
+			t.Errorf("expected the unset variable to go back to its default; got %#v", cfg.Host)
+		}
+		if cfg.Token == nil || *cfg.Token != "" {
+			t.Errorf("expected the unset pointer to be reset; got %#v", cfg.Token)
+		}
+		if cfg.Local != "kept" {
+			t.Errorf("expected fields without variables to be left alone; got %#v", cfg.Local)
+		}
+		if cfg.Inner != nil {
+			t.Errorf("expected the nested struct to go back to nil; got %#v", cfg.Inner)
+		}
+	}
+
+	env["X"] = "y"
+	if err := Reload(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while reloading: %v", err)
+		return
+	}
+	if cfg.Inner == nil || cfg.Inner.X != "y" {
+		t.Errorf("expected the nested struct to be allocated again; got %#v", cfg.Inner)
+	}
+}
+
+func TestReloadEncoded(t *testing.T) {
+	type limits struct {
+		Max int `json:"max"`
+	}
+	type config struct {
+		Limits limits         `env:"LIMITS" encoding:"json"`
+		Counts map[string]int `env:"COUNTS" encoding:"json"`
+		Key    [2]byte        `env:"KEY" encoding:"hex"`
+	}
+
+	env := MapLookuper{"LIMITS": `{"max":5}`, "COUNTS": `{"a":1}`, "KEY": "abcd"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	env["COUNTS"] = `{"b":2}`
+	delete(env, "LIMITS")
+	delete(env, "KEY")
+
+	if err := Reload(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while reloading: %v", err)
+		return
+	}
+	if cfg.Limits != (limits{}) {
+		t.Errorf("expected the unset JSON field to be reset; got %#v", cfg.Limits)
+	}
+	if !reflect.DeepEqual(cfg.Counts, map[string]int{"b": 2}) {
+		t.Errorf("expected the JSON map to be replaced rather than merged; got %#v", cfg.Counts)
+	}
+	if cfg.Key != [2]byte{} {
+		t.Errorf("expected the unset hex field to be reset; got %#v", cfg.Key)
+	}
+}
+
+func TestReloadImmutable(t *testing.T) {
+	type config struct {
+		Port int    `env:"PORT" immutable:"true"`
+		Host string `env:"HOST"`
+	}
+
+	env := MapLookuper{"PORT": "8000", "HOST": "a"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	env["HOST"] = "b"
+	if err := Reload(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("expected reload with an unchanged immutable field to succeed; got %v", err)
+	}
+	if cfg.Host != "b" {
+		t.Errorf("expected the mutable field to be reloaded; got %#v", cfg.Host)
+	}
+
+	env["PORT"] = "9000"
+	var immutable *ErrorImmutable
+	if err := Reload(&cfg, WithLookuper(env)); !errors.As(err, &immutable) || immutable.Name != "PORT" {
+		t.Errorf("expected an ErrorImmutable for PORT; got %v", err)
+	}
+	if cfg.Port != 8000 {
+		t.Errorf("expected the immutable field to be left alone; got %#v", cfg.Port)
+	}
+}
+
+func TestTrimCutset(t *testing.T) {
+	type config struct {
+		A string `env:"A" trimcutset:"\"'"`
+		B int    `env:"B" trimcutset:"[]"`
+		C string `env:"C,trim" trimcutset:"'"`
+	}
+
+	env := MapLookuper{"A": `"'us-east-1'"`, "B": "[42]", "C": " 'x' "}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.A != "us-east-1" {
+		t.Errorf("failed trimming quotes; expected %#v, got %#v", "us-east-1", cfg.A)
+	}
+	if cfg.B != 42 {
+		t.Errorf("failed trimming brackets; expected %#v, got %#v", 42, cfg.B)
+	}
+	if cfg.C != "x" {
+		t.Errorf("failed trimming whitespace and quotes; expected %#v, got %#v", "x", cfg.C)
+	}
+}
+
+func TestStringLength(t *testing.T) {
+	type config struct {
+		Username string  `env:"USERNAME" minlen:"3" maxlen:"5"`
+		Nickname *string `env:"NICKNAME" maxlen:"2"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"USERNAME": "héllo", "NICKNAME": "jo"})); err != nil {
+		t.Errorf("expected lengths to be counted in runes; got %v", err)
+	}
+	if cfg.Username != "héllo" {
+		t.Errorf("failed parsing valid value; got %#v", cfg.Username)
+	}
+
+	for _, val := range []string{"jo", "janedoe"} {
+		err := Parse(&cfg, WithLookuper(MapLookuper{"USERNAME": val}))
+		var outOfRange *ErrorOutOfRange
+		if !errors.As(err, &outOfRange) {
+			t.Errorf("expected an ErrorOutOfRange for %#v; got %v", val, err)
+		} else if outOfRange.Length != len(val) || outOfRange.Name != "USERNAME" {
+			t.Errorf("unexpected error for %#v: %#v", val, outOfRange)
+		}
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"USERNAME": "jane"})); err != nil {
+		t.Errorf("expected an unset field to be left unchecked; got %v", err)
+	}
+
+	type messageConfig struct {
+		Username string `env:"USERNAME" maxlen:"5" errmsg:"Keep USERNAME short"`
+	}
+
+	var msg messageConfig
+	err := Parse(&msg, WithLookuper(MapLookuper{"USERNAME": "janedoe"}))
+	var outOfRange *ErrorOutOfRange
+	if !errors.As(err, &outOfRange) || err.Error() != "Keep USERNAME short" {
+		t.Errorf("expected the errmsg tag to replace the message; got %v", err)
+	}
+}
+
+// logLevel implements Unmarshaler
+type logLevel int
+
+func (l *logLevel) UnmarshalEnv(value string) error {
+	switch strings.ToLower(value) {
+	case "debug":
+		*l = -1
+	case "info":
+		*l = 0
+	case "warn":
+		*l = 1
+	default:
+		return fmt.Errorf("unknown log level %q", value)
+	}
+	return nil
+}
+
+func TestUnmarshaler(t *testing.T) {
+	type config struct {
+		Level    logLevel  `env:"LEVEL"`
+		Override *logLevel `env:"OVERRIDE"`
+		Fallback *logLevel `env:"FALLBACK" default:"warn"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"LEVEL": "debug", "OVERRIDE": "WARN"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Level != -1 {
+		t.Errorf("failed unmarshalling logLevel; expected %#v, got %#v", -1, cfg.Level)
+	}
+	if cfg.Override == nil || *cfg.Override != 1 {
+		t.Errorf("failed unmarshalling *logLevel; got %#v", cfg.Override)
+	}
+	if cfg.Fallback == nil || *cfg.Fallback != 1 {
+		t.Errorf("failed unmarshalling default into *logLevel; got %#v", cfg.Fallback)
+	}
+
+	cfg = config{}
+	if err := Parse(&cfg, WithLookuper(MapLookuper{})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Override != nil {
+		t.Errorf("expected unset *logLevel to be left nil; got %#v", cfg.Override)
+	}
+
+	var invalid *ErrorInvalidValue
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"OVERRIDE": "loud"})); !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue; got %v", err)
+	}
+}
+
+func TestUnsetToken(t *testing.T) {
+	type config struct {
+		Workers int     `env:"WORKERS" default:"4"`
+		Host    *string `env:"HOST" default:"localhost"`
+		Port    int     `env:"PORT" default:"8000"`
+	}
+
+	env := MapLookuper{"WORKERS": "__UNSET__", "HOST": "__UNSET__"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithUnsetToken("__UNSET__")); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Workers != 0 {
+		t.Errorf("expected the unset token to zero the field; got %#v", cfg.Workers)
+	}
+	if cfg.Host != nil {
+		t.Errorf("expected the unset token to leave the pointer nil; got %#v", cfg.Host)
+	}
+	if cfg.Port != 8000 {
+		t.Errorf("expected other fields to get their defaults; got %#v", cfg.Port)
+	}
+
+	var invalid *ErrorInvalidValue
+	if err := Parse(&cfg, WithLookuper(env)); !errors.As(err, &invalid) {
+		t.Errorf("expected the token to be parsed as a value without the option; got %v", err)
+	}
+
+	type requiredConfig struct {
+		N int `env:"N,required"`
+	}
+
+	var req requiredConfig
+	err := Parse(&req, WithLookuper(MapLookuper{"N": "__UNSET__"}), WithUnsetToken("__UNSET__"))
+	if !errors.Is(err, ErrorMissingRequired) {
+		t.Errorf("expected the unset token not to satisfy a required field; got %v", err)
+	}
+}
+
+func TestBoolWords(t *testing.T) {
+	type config struct {
+		A bool         `env:"A"`
+		B *bool        `env:"B"`
+		C []bool       `env:"C"`
+		D sql.NullBool `env:"D"`
+		E bool         `env:"E"`
+	}
+
+	env := MapLookuper{"A": "Ja", "B": "nein", "C": "ja,NEIN,true", "D": "ja", "E": "1"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithBoolWords([]string{"ja"}, []string{"nein"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if !cfg.A {
+		t.Errorf("failed parsing custom true word; got %#v", cfg.A)
+	}
+	if cfg.B == nil || *cfg.B {
+		t.Errorf("failed parsing custom false word into *bool; got %#v", cfg.B)
+	}
+	if expected := []bool{true, false, true}; !reflect.DeepEqual(cfg.C, expected) {
+		t.Errorf("failed parsing custom words in a list; expected %#v, got %#v", expected, cfg.C)
+	}
+	if !cfg.D.Valid || !cfg.D.Bool {
+		t.Errorf("failed parsing custom true word into sql.NullBool; got %#v", cfg.D)
+	}
+	if !cfg.E {
+		t.Errorf("expected the usual values to still be accepted; got %#v", cfg.E)
+	}
+
+	if err := Parse(&cfg, WithLookuper(env)); err == nil {
+		t.Error("expected an error parsing custom words without WithBoolWords")
+	}
+}
+
+func TestIntSize(t *testing.T) {
+	if strconv.IntSize < 64 {
+		t.Skip("int is narrower than 64 bits on this platform")
+	}
+
+	type config struct {
+		A int  `env:"A"`
+		B *int `env:"B"`
+	}
+
+	var cfg config
+	env := MapLookuper{"A": "3000000000", "B": "-3000000000"}
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.A != 3000000000 {
+		t.Errorf("expected an int above the int32 range; got %d", cfg.A)
+	}
+	if cfg.B == nil || *cfg.B != -3000000000 {
+		t.Errorf("expected an *int below the int32 range; got %v", cfg.B)
+	}
+
+	err := Parse(&cfg, WithLookuper(MapLookuper{"A": "9223372036854775808"}))
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue for an overflowing int; got %v", err)
+	}
+}
+
+func TestKeyValueStructs(t *testing.T) {
+	type server struct {
+		Host string `env:"HOST"`
+		Port int    `env:"PORT"`
+	}
+	type config struct {
+		Server  server  `env:"SERVER" kv:"true"`
+		Backup  *server `env:"BACKUP" kv:"true"`
+		Missing *server `env:"MISSING" kv:"true"`
+	}
+
+	env := MapLookuper{"SERVER": "host:x, port:8080", "BACKUP": "HOST:y"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if expected := (server{Host: "x", Port: 8080}); cfg.Server != expected {
+		t.Errorf("failed parsing key:value pairs; expected %#v, got %#v", expected, cfg.Server)
+	}
+	if cfg.Backup == nil || cfg.Backup.Host != "y" {
+		t.Errorf("failed parsing key:value pairs into a pointer; got %#v", cfg.Backup)
+	}
+	if cfg.Missing != nil {
+		t.Errorf("expected an unset pointer to be left nil; got %#v", cfg.Missing)
+	}
+
+	for _, val := range []string{"host:x,user:admin", "host", "port:eighty"} {
+		err := Parse(&cfg, WithLookuper(MapLookuper{"SERVER": val}))
+		var invalid *ErrorInvalidValue
+		if !errors.As(err, &invalid) {
+			t.Errorf("expected an ErrorInvalidValue for %q; got %v", val, err)
+		}
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	var (
+		s  string
+		n  int
+		u  uint
+		f  float64
+		b  bool
+		d  time.Duration
+		p  *int
+		l  []string
+		m  map[string]int
+		bi *big.Int
+	)
+
+	tests := []struct {
+		v        interface{}
+		s        string
+		expected interface{}
+	}{
+		{&s, "hello", "hello"},
+		{&n, "-42", -42},
+		{&u, "42", uint(42)},
+		{&f, "1.5", 1.5},
+		{&b, "true", true},
+		{&d, "30s", 30 * time.Second},
+		{&l, "a,b", []string{"a", "b"}},
+		{&m, "a=1,b=2", map[string]int{"a": 1, "b": 2}},
+		{&bi, "12345", big.NewInt(12345)},
+	}
+	for _, tt := range tests {
+		v := reflect.ValueOf(tt.v).Elem()
+		if err := SetValue(v, tt.s); err != nil {
+			t.Errorf("error setting %T from %q: %v", tt.v, tt.s, err)
+			continue
+		}
+		if got := v.Interface(); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("unexpected value setting %T from %q; expected %#v, got %#v", tt.v, tt.s, tt.expected, got)
+		}
+	}
+
+	if err := SetValue(reflect.ValueOf(&p).Elem(), "7"); err != nil || p == nil || *p != 7 {
+		t.Errorf("failed setting a pointer; got %v, %v", p, err)
+	}
+	if err := SetValue(reflect.ValueOf(&b).Elem(), "ja", WithBoolWords([]string{"ja"}, nil)); err != nil || !b {
+		t.Errorf("expected options to be taken into account; got %v, %v", b, err)
+	}
+	if err := SetValue(reflect.ValueOf(&n).Elem(), "x"); err == nil {
+		t.Error("expected an error setting an int from an invalid value")
+	}
+	if err := SetValue(reflect.ValueOf(n), "1"); err == nil {
+		t.Error("expected an error setting an unsettable value")
+	}
 }
@@ -0,0 +1,163 @@
+package babyenv
+
+import (
+	"errors"
+	"strings"
+)
+
+// Expand shell-style variable references in s, looking them up with the
+// Lookuper. The following forms are supported:
+//
+//     $VAR
+//     ${VAR}
+//     ${VAR:-fallback}
+//
+// The fallback is used when VAR is unset or empty, and may itself contain
+// references. Use $$ for a literal dollar sign.
+func (o *options) expand(s string) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		switch next := s[i+1]; {
+		case next == '$':
+			b.WriteByte('$')
+			i++
+
+		case next == '{':
+			end := closingBrace(s, i+1)
+			if end < 0 {
+				return "", errors.New("unclosed ${")
+			}
+			val, err := o.expandBraced(s[i+2 : end])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(val)
+			i = end
+
+		case isNameChar(next):
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			val, _, err := o.lookup(s[i+1 : j])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(val)
+			i = j - 1
+
+		default:
+			b.WriteByte('$')
+		}
+	}
+
+	return b.String(), nil
+}
+
+// Expand the contents of a ${...} reference.
+func (o *options) expandBraced(ref string) (string, error) {
+	name, fallback, hasFallback := ref, "", false
+	if i := strings.Index(ref, ":-"); i >= 0 {
+		name, fallback, hasFallback = ref[:i], ref[i+2:], true
+	}
+
+	val, _, err := o.lookup(name)
+	if err != nil {
+		return "", err
+	}
+	if val == "" && hasFallback {
+		return o.expand(fallback)
+	}
+	return val, nil
+}
+
+// Find the index of the brace closing the one at s[start], accounting for
+// nested references. Returns -1 if there isn't one.
+func closingBrace(s string, start int) int {
+	depth := 0
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Substitute references to the values of fields parsed earlier, written as
+// {NAME} where NAME is the field's variable name, before any prefix is added.
+// Braces that don't contain a name, or that follow a $, are left alone so JSON
+// and shell-style references pass through.
+func (o *options) substituteFields(s string) (string, error) {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		name, end := fieldReference(s, i)
+		if end < 0 {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		val, ok := o.resolved[o.composeName(name)]
+		if !ok {
+			return "", errors.New(name + " must be parsed before it can be referenced")
+		}
+		b.WriteString(val)
+		i = end
+	}
+
+	return b.String(), nil
+}
+
+// Report whether s refers to the value of another field, such as
+// {DATA_DIR}/cache.
+func hasFieldReference(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if _, end := fieldReference(s, i); end >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the name in a field reference starting at s[i], along with the index
+// of its closing brace. The index is -1 if there's no reference there.
+func fieldReference(s string, i int) (string, int) {
+	if s[i] != '{' || (i > 0 && s[i-1] == '$') {
+		return "", -1
+	}
+
+	end := strings.IndexByte(s[i:], '}')
+	if end < 0 || !isName(s[i+1:i+end]) {
+		return "", -1
+	}
+	return s[i+1 : i+end], i + end
+}
+
+// Report whether s is a non-empty variable name.
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
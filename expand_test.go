@@ -0,0 +1,160 @@
+package babyenv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestShellDefaults(t *testing.T) {
+	type config struct {
+		ConfigDir string `env:"CONFIG_DIR" default:"${XDG_CONFIG_HOME:-$HOME/.config}/app"`
+		Price     string `env:"PRICE" default:"$$5"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{
+		"XDG_CONFIG_HOME": "/etc/xdg",
+		"HOME":            "/home/jane",
+	}), WithShellDefaults()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.ConfigDir != "/etc/xdg/app" {
+		t.Errorf("failed expanding primary var; expected %#v, got %#v", "/etc/xdg/app", cfg.ConfigDir)
+	}
+	if cfg.Price != "$5" {
+		t.Errorf("failed expanding escaped dollar sign; expected %#v, got %#v", "$5", cfg.Price)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{
+		"HOME": "/home/jane",
+	}), WithShellDefaults()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.ConfigDir != "/home/jane/.config/app" {
+		t.Errorf("failed expanding fallback; expected %#v, got %#v", "/home/jane/.config/app", cfg.ConfigDir)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"HOME": "/home/jane"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.ConfigDir != "${XDG_CONFIG_HOME:-$HOME/.config}/app" {
+		t.Errorf("expected defaults not to be expanded by default; got %#v", cfg.ConfigDir)
+	}
+
+	type badConfig struct {
+		A string `env:"A" default:"${UNCLOSED"`
+	}
+	var bad badConfig
+	if err := Parse(&bad, WithLookuper(MapLookuper{}), WithShellDefaults()); err == nil {
+		t.Error("expected an error expanding an unclosed reference")
+	}
+}
+
+func TestFieldReferenceDefaults(t *testing.T) {
+	type config struct {
+		DataDir  string `env:"DATA_DIR" default:"/var/lib/app"`
+		CacheDir string `env:"CACHE_DIR" default:"{DATA_DIR}/cache"`
+		Limits   string `env:"LIMITS" default:"{}"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.CacheDir != "/var/lib/app/cache" {
+		t.Errorf("failed substituting default; expected %#v, got %#v", "/var/lib/app/cache", cfg.CacheDir)
+	}
+	if cfg.Limits != "{}" {
+		t.Errorf("expected braces without a name to be left alone; got %#v", cfg.Limits)
+	}
+
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"DATA_DIR": "/data"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.CacheDir != "/data/cache" {
+		t.Errorf("failed substituting value from the environment; expected %#v, got %#v", "/data/cache", cfg.CacheDir)
+	}
+
+	type badConfig struct {
+		CacheDir string `env:"CACHE_DIR" default:"{DATA_DIR}/cache"`
+		DataDir  string `env:"DATA_DIR" default:"/var/lib/app"`
+	}
+
+	var bad badConfig
+	if err := Parse(&bad, WithLookuper(MapLookuper{})); err == nil {
+		t.Error("expected an error referencing a field that comes later")
+	}
+
+	type dirs struct {
+		DataDir  string `env:"DATA_DIR" default:"/var/lib/app"`
+		CacheDir string `env:"CACHE_DIR" default:"{DATA_DIR}/cache"`
+	}
+	type prefixedConfig struct {
+		Primary dirs `envPrefix:"PRIMARY_"`
+	}
+
+	var prefixed prefixedConfig
+	if err := Parse(&prefixed, WithLookuper(MapLookuper{"PRIMARY_DATA_DIR": "/primary"})); err != nil {
+		t.Errorf("error while parsing with a prefix: %v", err)
+		return
+	}
+	if prefixed.Primary.CacheDir != "/primary/cache" {
+		t.Errorf("failed substituting prefixed value; expected %#v, got %#v", "/primary/cache", prefixed.Primary.CacheDir)
+	}
+}
+
+func TestExpandValues(t *testing.T) {
+	type config struct {
+		ConfigDir string `env:"CONFIG_DIR" default:"$HOME/.config"`
+		Password  string `env:"PASSWORD"`
+	}
+
+	env := MapLookuper{"HOME": "/home/jane", "PASSWORD": "pa$HOMEss"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithExpandDefaults()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.ConfigDir != "/home/jane/.config" {
+		t.Errorf("failed expanding default; expected %#v, got %#v", "/home/jane/.config", cfg.ConfigDir)
+	}
+	if cfg.Password != "pa$HOMEss" {
+		t.Errorf("expected value with a literal $ to be left alone; got %#v", cfg.Password)
+	}
+
+	env["PASSWORD"] = "${HOME}/secret"
+	if err := Parse(&cfg, WithLookuper(env), WithExpandValues()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Password != "/home/jane/secret" {
+		t.Errorf("failed expanding value; expected %#v, got %#v", "/home/jane/secret", cfg.Password)
+	}
+	if cfg.ConfigDir != "$HOME/.config" {
+		t.Errorf("expected default not to be expanded; got %#v", cfg.ConfigDir)
+	}
+
+	type secretConfig struct {
+		Password string `env:"PASSWORD" secret:"true"`
+	}
+	var secret secretConfig
+	env["PASSWORD"] = "hunter2${oops"
+	err := Parse(&secret, WithLookuper(env), WithExpandValues())
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue for a bad reference; got %v", err)
+		return
+	}
+	for e := error(invalid); e != nil; e = errors.Unwrap(e) {
+		if strings.Contains(e.Error(), "hunter2") {
+			t.Errorf("expected secret value to be redacted; got %v", e)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package babyenv
+
+import "flag"
+
+// Note the values of the flags that were set, if we have a flag set.
+func (o *options) collectFlags() {
+	if o.flags == nil {
+		return
+	}
+	o.flagsSet = make(map[string]string)
+	o.flags.Visit(func(f *flag.Flag) {
+		o.flagsSet[f.Name] = f.Value.String()
+	})
+}
+
+// Get the value of the named flag if it was set.
+func (o *options) flagValue(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	v, ok := o.flagsSet[name]
+	return v, ok
+}
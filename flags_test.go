@@ -0,0 +1,39 @@
+package babyenv
+
+import (
+	"flag"
+	"testing"
+)
+
+func TestFlagOverrides(t *testing.T) {
+	type config struct {
+		Port    int    `env:"PORT" flag:"port" default:"8000"`
+		Host    string `env:"HOST" flag:"host"`
+		Workers int    `env:"WORKERS" flag:"workers" default:"4"`
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("port", 0, "port to listen on")
+	fs.String("host", "0.0.0.0", "host to listen on")
+	fs.Int("workers", 1, "number of workers")
+	if err := fs.Parse([]string{"-port", "9000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	env := MapLookuper{"PORT": "8080", "HOST": "localhost"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithFlagOverrides(fs)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Port != 9000 {
+		t.Errorf("expected flag to override the environment; expected %d, got %d", 9000, cfg.Port)
+	}
+	if cfg.Host != "localhost" {
+		t.Errorf("expected unset flag not to override the environment; expected %#v, got %#v", "localhost", cfg.Host)
+	}
+	if cfg.Workers != 4 {
+		t.Errorf("expected unset flag not to override the default; expected %d, got %d", 4, cfg.Workers)
+	}
+}
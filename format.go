@@ -0,0 +1,154 @@
+package babyenv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A single problem from an ErrorList, broken down for DebugString
+type problem struct {
+	field string
+	name  string
+	msg   string
+	notes []string
+}
+
+// DebugString renders the errors as a multi-line summary, suitable for
+// printing at startup, grouped by field. Each entry gives the field, its
+// environment variable, the problem and any description or custom message:
+//
+//     2 problems with the environment:
+//       Port (PORT): invalid value "http": strconv.ParseInt: parsing "http": invalid syntax
+//       Name (NAME): missing
+//         description: the display name shown to users
+func (e ErrorList) DebugString() string {
+	var (
+		problems = make(map[string][]problem)
+		fields   []string
+	)
+	for _, err := range e {
+		p := describeError(err)
+		if _, ok := problems[p.field]; !ok {
+			fields = append(fields, p.field)
+		}
+		problems[p.field] = append(problems[p.field], p)
+	}
+
+	var b strings.Builder
+	if len(e) == 1 {
+		b.WriteString("1 problem with the environment:\n")
+	} else {
+		fmt.Fprintf(&b, "%d problems with the environment:\n", len(e))
+	}
+	for _, field := range fields {
+		for _, p := range problems[field] {
+			b.WriteString("  ")
+			switch {
+			case p.field != "" && p.name != "":
+				fmt.Fprintf(&b, "%s (%s): ", p.field, p.name)
+			case p.field != "":
+				fmt.Fprintf(&b, "%s: ", p.field)
+			case p.name != "":
+				fmt.Fprintf(&b, "%s: ", p.name)
+			}
+			b.WriteString(p.msg)
+			b.WriteByte('\n')
+			for _, note := range p.notes {
+				fmt.Fprintf(&b, "    %s\n", note)
+			}
+		}
+	}
+	return b.String()
+}
+
+// FieldError is one of the errors handed to a formatter set with
+// WithErrorFormatter. FieldName holds the path to the field the error concerns
+// and Name its environment variable, when they're known.
+type FieldError struct {
+	FieldName string
+	Name      string
+	Err       error
+}
+
+// Error implements the error interface
+func (e FieldError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Hand an ErrorList to the formatter set with WithErrorFormatter, if there is
+// one. Other errors are returned untouched.
+func (o *options) formatErrors(err error) error {
+	list, ok := err.(ErrorList)
+	if !ok || o.errorFormatter == nil {
+		return err
+	}
+	errs := make([]FieldError, len(list))
+	for i, e := range list {
+		p := describeError(e)
+		errs[i] = FieldError{FieldName: p.field, Name: p.name, Err: e}
+	}
+	return o.errorFormatter(errs)
+}
+
+// Break an error down into the field and variable it concerns and what went
+// wrong.
+func describeError(err error) problem {
+	var (
+		required    *ErrorEnvVarRequired
+		invalid     *ErrorInvalidValue
+		unsupported *ErrorUnsupportedType
+		unsettable  *ErrorUnsettable
+		immutable   *ErrorImmutable
+		outOfRange  *ErrorOutOfRange
+	)
+
+	switch {
+	case errors.As(err, &required):
+		p := problem{field: required.FieldName, name: required.Name, msg: "missing"}
+		if required.Desc != "" {
+			p.notes = append(p.notes, "description: "+required.Desc)
+		}
+		if required.Message != "" {
+			p.notes = append(p.notes, required.Message)
+		}
+		return p
+
+	case errors.As(err, &invalid):
+		p := problem{field: invalid.FieldName, name: invalid.Name}
+		if invalid.Secret {
+			p.msg = "invalid value " + redacted
+		} else {
+			p.msg = fmt.Sprintf("invalid value %q: %v", invalid.Value, invalid.Err)
+		}
+		if invalid.Message != "" {
+			p.notes = append(p.notes, invalid.Message)
+		}
+		return p
+
+	case errors.As(err, &unsupported):
+		return problem{field: unsupported.FieldName, name: unsupported.Name, msg: fmt.Sprintf("unsupported type %v", unsupported.Type)}
+
+	case errors.As(err, &unsettable):
+		return problem{field: unsettable.FieldName, msg: "can't be set"}
+
+	case errors.As(err, &immutable):
+		return problem{field: immutable.FieldName, name: immutable.Name, msg: "can't be changed by reloading"}
+
+	case errors.As(err, &outOfRange):
+		bare := *outOfRange
+		bare.Message = ""
+		p := problem{field: outOfRange.FieldName, name: outOfRange.Name, msg: strings.TrimPrefix(bare.Error(), bare.Name+" ")}
+		if outOfRange.Message != "" {
+			p.notes = append(p.notes, outOfRange.Message)
+		}
+		return p
+	}
+
+	return problem{msg: err.Error()}
+}
@@ -0,0 +1,100 @@
+package babyenv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestErrorListDebugString(t *testing.T) {
+	type config struct {
+		Name   string `env:"NAME,required" desc:"the display name shown to users"`
+		Port   int    `env:"PORT" errmsg:"Set PORT to the port to listen on"`
+		Secret int    `env:"SECRET" secret:"true"`
+		Token  string `env:"TOKEN,required"`
+	}
+
+	var cfg config
+	err := Parse(&cfg, WithLookuper(MapLookuper{"PORT": "http", "SECRET": "hunter2"}), WithCollectErrors())
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Errorf("expected an ErrorList; got %v", err)
+		return
+	}
+
+	out := list.DebugString()
+	for _, want := range []string{
+		"4 problems with the environment:\n",
+		"  Name (NAME): missing\n",
+		"    description: the display name shown to users\n",
+		"  Port (PORT): invalid value \"http\": ",
+		"    Set PORT to the port to listen on\n",
+		"  Secret (SECRET): invalid value ****\n",
+		"  Token (TOKEN): missing\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q; got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "hunter2") {
+		t.Errorf("expected secret value to be redacted; got:\n%s", out)
+	}
+}
+
+func TestErrorFormatter(t *testing.T) {
+	type config struct {
+		Name string `env:"NAME,required"`
+		Port int    `env:"PORT"`
+	}
+
+	var got []FieldError
+	formatter := func(errs []FieldError) error {
+		got = errs
+		parts := make([]string, len(errs))
+		for i, e := range errs {
+			parts[i] = e.FieldName + "=" + e.Name
+		}
+		return errors.New("config: " + strings.Join(parts, " "))
+	}
+
+	var cfg config
+	env := MapLookuper{"PORT": "http"}
+	err := Parse(&cfg, WithLookuper(env), WithCollectErrors(), WithErrorFormatter(formatter))
+	if err == nil || err.Error() != "config: Name=NAME Port=PORT" {
+		t.Errorf("unexpected formatted error; got %v", err)
+	}
+	if len(got) != 2 {
+		t.Errorf("expected the formatter to receive 2 errors; got %d", len(got))
+		return
+	}
+	var required *ErrorEnvVarRequired
+	if !errors.As(got[0], &required) {
+		t.Errorf("expected the original error to be unwrappable; got %v", got[0].Err)
+	}
+
+	// Errors that weren't collected pass through untouched
+	err = Parse(&cfg, WithLookuper(env), WithErrorFormatter(formatter))
+	if _, ok := err.(*ErrorEnvVarRequired); !ok {
+		t.Errorf("expected an uncollected error to be returned as is; got %v", err)
+	}
+
+	if errs := ParseLenient(&cfg, WithLookuper(env), WithErrorFormatter(formatter)); len(errs) != 2 {
+		t.Errorf("expected ParseLenient to ignore the formatter; got %v", errs)
+	}
+
+	type other struct {
+		Workers int `env:"WORKERS"`
+	}
+
+	var o other
+	env["WORKERS"] = "many"
+	err = ParseMultiple(&cfg, &o, WithLookuper(env), WithCollectErrors(), WithErrorFormatter(formatter))
+	if err == nil || err.Error() != "config: Name=NAME Port=PORT Workers=WORKERS" {
+		t.Errorf("expected ParseMultiple to format every error at once; got %v", err)
+	}
+
+	err = ParseFields(&cfg, []string{"Name", "Port"}, WithLookuper(env), WithCollectErrors(), WithErrorFormatter(formatter))
+	if err == nil || err.Error() != "config: Name=NAME Port=PORT" {
+		t.Errorf("expected ParseFields to format errors; got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package babyenv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Report whether a variable name is a pattern, such as ROUTE_*, for collecting
+// several variables into a map.
+func isGlob(name string) bool {
+	return strings.Count(name, "*") == 1
+}
+
+// Collect every variable matching a pattern into a map, keyed by the part of
+// the name matched by the *. The Lookuper has to implement Enumerator. The map
+// is left nil if nothing matches.
+func (o *options) parseGlob(field reflect.Value, info *fieldInfo, pattern string) (bool, error) {
+	t := field.Type()
+	if t.Key().Kind() != reflect.String || !isScalar(t.Elem()) {
+		return false, &ErrorUnsupportedType{Type: t, FieldName: o.fieldPath(info), Name: pattern}
+	}
+
+	keys, err := o.matchGlob(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	m := reflect.MakeMap(t)
+	matched := make(map[string]string)
+	var entries []string
+	for _, k := range keys {
+		val, _ := o.lookuper.LookupEnv(k)
+		elem := reflect.New(t.Elem()).Elem()
+		if err := setElem(elem, val); err != nil {
+			return false, o.invalidValue(info, k, val, err)
+		}
+		key := k[len(prefix) : len(k)-len(suffix)]
+		m.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		entries = append(entries, fmt.Sprintf("%s=%s", key, val))
+		matched[k] = val
+	}
+
+	if len(entries) == 0 {
+		if info.opts.required {
+			return false, &ErrorEnvVarRequired{Name: pattern, FieldName: o.fieldPath(info), Desc: info.tags.Get("desc"), Message: info.tags.Get("errmsg")}
+		}
+		field.Set(reflect.Zero(t))
+		o.record(info, "", sourceZero)
+		return false, nil
+	}
+
+	field.Set(m)
+	o.matched[pattern] = matched
+	o.record(info, strings.Join(entries, ","), sourceEnv)
+	return true, nil
+}
+
+// List the names of the variables matching a pattern, in order. The Lookuper
+// has to implement Enumerator.
+func (o *options) matchGlob(pattern string) ([]string, error) {
+	enum, ok := o.lookuper.(Enumerator)
+	if !ok {
+		return nil, errors.New("matching " + pattern + " requires a Lookuper that implements Enumerator")
+	}
+
+	prefix, suffix, _ := strings.Cut(pattern, "*")
+	keys := enum.Keys()
+	sort.Strings(keys)
+
+	var matches []string
+	for _, k := range keys {
+		if len(k) > len(prefix)+len(suffix) && strings.HasPrefix(k, prefix) && strings.HasSuffix(k, suffix) {
+			matches = append(matches, k)
+		}
+	}
+	return matches, nil
+}
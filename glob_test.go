@@ -0,0 +1,90 @@
+package babyenv
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestGlobMaps(t *testing.T) {
+	type config struct {
+		Routes  map[string]string `env:"ROUTE_*"`
+		Weights map[string]int    `env:"*_WEIGHT"`
+		Missing map[string]string `env:"NOPE_*"`
+	}
+
+	env := MapLookuper{
+		"ROUTE_A":    "/a",
+		"ROUTE_B":    "/b",
+		"ROUTE_API":  "/api",
+		"ROUTE_":     "ignored",
+		"A_WEIGHT":   "1",
+		"B_WEIGHT":   "2",
+		"UNRELATED":  "x",
+		"ROUTEX_BAD": "x",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if expected := map[string]string{"A": "/a", "B": "/b", "API": "/api"}; !reflect.DeepEqual(cfg.Routes, expected) {
+		t.Errorf("failed collecting ROUTE_*; expected %#v, got %#v", expected, cfg.Routes)
+	}
+	if expected := map[string]int{"A": 1, "B": 2}; !reflect.DeepEqual(cfg.Weights, expected) {
+		t.Errorf("failed collecting *_WEIGHT; expected %#v, got %#v", expected, cfg.Weights)
+	}
+	if cfg.Missing != nil {
+		t.Errorf("expected an unmatched pattern to leave the map nil; got %#v", cfg.Missing)
+	}
+
+	env["B_WEIGHT"] = "heavy"
+	var invalid *ErrorInvalidValue
+	if err := Parse(&cfg, WithLookuper(env)); !errors.As(err, &invalid) || invalid.Name != "B_WEIGHT" || invalid.FieldName != "Weights" {
+		t.Errorf("expected an ErrorInvalidValue naming B_WEIGHT and its field; got %v", err)
+	}
+
+	type messageConfig struct {
+		Weights map[string]int `env:"*_WEIGHT" errmsg:"Weights must be numbers"`
+	}
+	var msg messageConfig
+	if err := Parse(&msg, WithLookuper(env)); err == nil || err.Error() != "Weights must be numbers" {
+		t.Errorf("expected the errmsg tag to replace the message; got %v", err)
+	}
+}
+
+func TestValidateGlobMaps(t *testing.T) {
+	type config struct {
+		Routes map[string]string `env:"ROUTE_*,required"`
+	}
+
+	missing, err := Validate(config{}, WithLookuper(MapLookuper{"ROUTE_A": "/a"}))
+	if err != nil || len(missing) != 0 {
+		t.Errorf("expected a matched pattern not to be missing; got %v, %v", missing, err)
+	}
+
+	missing, err = Validate(config{}, WithLookuper(MapLookuper{"OTHER": "x"}))
+	if err != nil || !reflect.DeepEqual(missing, []string{"ROUTE_*"}) {
+		t.Errorf("expected an unmatched pattern to be missing; got %v, %v", missing, err)
+	}
+}
+
+// lookuperFunc is a Lookuper that can't enumerate its variables
+type lookuperFunc func(string) (string, bool)
+
+func (f lookuperFunc) LookupEnv(name string) (string, bool) {
+	return f(name)
+}
+
+func TestGlobMapsRequireEnumerator(t *testing.T) {
+	type config struct {
+		Routes map[string]string `env:"ROUTE_*"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(lookuperFunc(func(string) (string, bool) { return "", false }))); err == nil {
+		t.Error("expected an error matching a pattern without an Enumerator")
+	}
+}
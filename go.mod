@@ -1,3 +1,3 @@
 module github.com/meowgorithm/babyenv
 
-go 1.13
+go 1.21
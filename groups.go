@@ -0,0 +1,62 @@
+package babyenv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorIncompleteGroup is used with WithRequiredGroups when some, but not
+// all, of the variables in a group were set
+type ErrorIncompleteGroup struct {
+	Group   string
+	Missing []string
+}
+
+// Error implements the error interface
+func (e *ErrorIncompleteGroup) Error() string {
+	return fmt.Sprintf("group %s is incomplete; missing %s", e.Group, strings.Join(e.Missing, ", "))
+}
+
+// A variable belonging to a group, and whether it was set
+type groupMember struct {
+	name string
+	set  bool
+}
+
+// Note whether a field in one of the required groups was set.
+func (o *options) noteGroup(info *fieldInfo, set bool) {
+	group := info.tags.Get("group")
+	if group == "" || !o.requiredGroups[group] {
+		return
+	}
+	if _, ok := o.groupMembers[group]; !ok {
+		o.groupNames = append(o.groupNames, group)
+	}
+	o.groupMembers[group] = append(o.groupMembers[group], groupMember{o.envName(info), set})
+}
+
+// Check that each required group was either filled in completely or left
+// out entirely. An ErrorList of ErrorIncompleteGroup is returned otherwise.
+func (o *options) checkGroups() error {
+	var errs ErrorList
+
+	for _, group := range o.groupNames {
+		var missing []string
+		anySet := false
+		for _, m := range o.groupMembers[group] {
+			if m.set {
+				anySet = true
+			} else {
+				missing = append(missing, m.name)
+			}
+		}
+		if anySet && len(missing) > 0 {
+			errs = append(errs, &ErrorIncompleteGroup{Group: group, Missing: missing})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package babyenv
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRequiredGroups(t *testing.T) {
+	type config struct {
+		User  string `env:"DB_USER" group:"db"`
+		Pass  string `env:"DB_PASS" group:"db"`
+		Host  string `env:"DB_HOST" group:"db" default:"localhost"`
+		Cache string `env:"CACHE_URL" group:"cache"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{}), WithRequiredGroups("db")); err != nil {
+		t.Errorf("expected an empty group to be allowed; got %v", err)
+	}
+
+	env := MapLookuper{"DB_USER": "jane", "DB_HOST": "db.internal"}
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("expected groups to be ignored unless they're required; got %v", err)
+	}
+
+	err := Parse(&cfg, WithLookuper(env), WithRequiredGroups("db"))
+
+	var incomplete *ErrorIncompleteGroup
+	if !errors.As(err, &incomplete) {
+		t.Fatalf("expected an ErrorIncompleteGroup; got %v", err)
+	}
+	if incomplete.Group != "db" || !reflect.DeepEqual(incomplete.Missing, []string{"DB_PASS"}) {
+		t.Errorf("expected DB_PASS to be reported missing from db; got %#v", incomplete)
+	}
+
+	env["DB_PASS"] = "hunter2"
+	if err := Parse(&cfg, WithLookuper(env), WithRequiredGroups("db")); err != nil {
+		t.Errorf("expected a complete group to be allowed; got %v", err)
+	}
+}
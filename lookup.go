@@ -0,0 +1,152 @@
+package babyenv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ErrorAmbiguousName is used when a case-insensitive lookup matches more than
+// one environment variable
+type ErrorAmbiguousName struct {
+	Name    string
+	Matches []string
+}
+
+// Error implements the error interface
+func (e *ErrorAmbiguousName) Error() string {
+	return fmt.Sprintf("%s is ambiguous; it matches %s", e.Name, strings.Join(e.Matches, ", "))
+}
+
+// Lookuper looks up the values of environment variables. By default the
+// process environment is used.
+type Lookuper interface {
+	// LookupEnv returns the value of the variable with the given name and
+	// whether or not it was set.
+	LookupEnv(name string) (string, bool)
+}
+
+// Enumerator is a Lookuper which can also list the names of all the variables
+// it knows about. Some features, such as case-insensitive lookups, require
+// one.
+type Enumerator interface {
+	Lookuper
+	Keys() []string
+}
+
+// MapLookuper is a Lookuper backed by a map of variable names to values.
+type MapLookuper map[string]string
+
+// LookupEnv implements the Lookuper interface
+func (m MapLookuper) LookupEnv(name string) (string, bool) {
+	v, ok := m[name]
+	return v, ok
+}
+
+// Keys implements the Enumerator interface
+func (m MapLookuper) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// ParseEnviron parses the struct using variables from a slice of KEY=VALUE
+// strings, in the format returned by os.Environ, rather than the process
+// environment. If a key appears more than once the last value wins.
+func ParseEnviron(environ []string, cfg interface{}, opts ...Option) error {
+	return Parse(cfg, append(opts, WithLookuper(environToMap(environ)))...)
+}
+
+// Convert KEY=VALUE strings to a map, letting later keys override earlier
+// ones. Entries without a name are skipped.
+func environToMap(environ []string) MapLookuper {
+	env := make(MapLookuper, len(environ))
+	for _, kv := range environ {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return env
+}
+
+// LookupEnv is used to read variables from the process environment when no
+// Lookuper has been provided. It can be replaced in tests, though it's not
+// safe to do so while parsing is taking place in another goroutine.
+var LookupEnv = os.LookupEnv
+
+// osLookuper reads from the process environment.
+type osLookuper struct{}
+
+func (osLookuper) LookupEnv(name string) (string, bool) {
+	return LookupEnv(name)
+}
+
+func (osLookuper) Keys() []string {
+	return environToMap(os.Environ()).Keys()
+}
+
+// When reading from the process environment, read it through a processEnv for
+// the rest of the parse.
+func (o *options) useProcessEnv() {
+	if _, ok := o.lookuper.(osLookuper); ok {
+		o.lookuper = &processEnv{}
+	}
+}
+
+// processEnv reads from the process environment for the duration of a single
+// parse. Variables are looked up with LookupEnv as they're needed, so lookups
+// behave as os.LookupEnv does on every platform, including ignoring case on
+// Windows. The names of all the variables are only listed if something needs
+// to enumerate them, and then only once, so every field matching a pattern
+// sees the same variables.
+type processEnv struct {
+	keys []string
+}
+
+func (e *processEnv) LookupEnv(name string) (string, bool) {
+	return LookupEnv(name)
+}
+
+func (e *processEnv) Keys() []string {
+	if e.keys == nil {
+		e.keys = osLookuper{}.Keys()
+	}
+	return append([]string(nil), e.keys...)
+}
+
+// Look up an environment variable, falling back to a case-insensitive search
+// if we've been asked to and there's no exact match.
+func (o *options) lookup(name string) (string, bool, error) {
+	if v, ok := o.lookuper.LookupEnv(name); ok || !o.caseInsensitive {
+		return v, ok, nil
+	}
+
+	enum, ok := o.lookuper.(Enumerator)
+	if !ok {
+		return "", false, errors.New("case-insensitive lookups require a Lookuper that implements Enumerator")
+	}
+
+	keys := enum.Keys()
+	sort.Strings(keys)
+
+	var matches []string
+	for _, k := range keys {
+		if strings.EqualFold(k, name) {
+			matches = append(matches, k)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", false, nil
+	case 1:
+		v, ok := o.lookuper.LookupEnv(matches[0])
+		return v, ok, nil
+	default:
+		return "", false, &ErrorAmbiguousName{Name: name, Matches: matches}
+	}
+}
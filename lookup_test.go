@@ -0,0 +1,175 @@
+package babyenv
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLookuper(t *testing.T) {
+	type config struct {
+		A string `env:"A"`
+		B int    `env:"B" default:"16"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"A": "xxx"})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != "xxx" {
+		t.Errorf("failed reading from lookuper; expected %#v, got %#v", "xxx", cfg.A)
+	}
+	if cfg.B != 16 {
+		t.Errorf("failed setting default; expected %#v, got %#v", 16, cfg.B)
+	}
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	type config struct {
+		Name string `env:"NAME"`
+		Port string `env:"PORT"`
+	}
+
+	env := MapLookuper{
+		"name": "Jane",
+		"PORT": "8000",
+		"Port": "9000",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Name != "" {
+		t.Errorf("expected no case-insensitive match by default; got %#v", cfg.Name)
+	}
+
+	cfg = config{}
+	if err := Parse(&cfg, WithLookuper(env), WithCaseInsensitive()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Name != "Jane" {
+		t.Errorf("failed case-insensitive lookup; expected %#v, got %#v", "Jane", cfg.Name)
+	}
+	if cfg.Port != "8000" {
+		t.Errorf("expected exact match to take precedence; expected %#v, got %#v", "8000", cfg.Port)
+	}
+
+	delete(env, "PORT")
+	env["port"] = "7000"
+
+	var ambiguous *ErrorAmbiguousName
+	err := Parse(&cfg, WithLookuper(env), WithCaseInsensitive())
+	if !errors.As(err, &ambiguous) {
+		t.Errorf("expected an ErrorAmbiguousName; got %v", err)
+	} else if len(ambiguous.Matches) != 2 || ambiguous.Matches[0] != "Port" || ambiguous.Matches[1] != "port" {
+		t.Errorf("expected sorted matches [Port port]; got %v", ambiguous.Matches)
+	}
+}
+
+func TestLookupEnvOverride(t *testing.T) {
+	type config struct {
+		A string `env:"A"`
+		B int    `env:"B"`
+	}
+
+	env := map[string]string{"A": "xxx", "B": "16"}
+
+	orig := LookupEnv
+	defer func() { LookupEnv = orig }()
+	LookupEnv = func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	var cfg config
+	if err := Parse(&cfg); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != "xxx" {
+		t.Errorf("failed reading from LookupEnv; expected %#v, got %#v", "xxx", cfg.A)
+	}
+	if cfg.B != 16 {
+		t.Errorf("failed reading from LookupEnv; expected %#v, got %#v", 16, cfg.B)
+	}
+}
+
+func TestEnvironSnapshot(t *testing.T) {
+	type config struct {
+		A map[string]string `env:"SNAPSHOT_A_*"`
+		S string            `env:"SNAPSHOT_S" default:"@changeEnv"`
+		B map[string]string `env:"SNAPSHOT_B_*"`
+	}
+
+	os.Unsetenv("SNAPSHOT_S")
+	os.Setenv("SNAPSHOT_A_X", "a")
+	defer os.Unsetenv("SNAPSHOT_A_X")
+	defer os.Unsetenv("SNAPSHOT_B_X")
+
+	// Changes the environment while we're parsing
+	changeEnv := func() (string, error) {
+		os.Setenv("SNAPSHOT_B_X", "b")
+		return "s", nil
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithDefaultFuncs(map[string]func() (string, error){"changeEnv": changeEnv})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if !reflect.DeepEqual(cfg.A, map[string]string{"X": "a"}) {
+		t.Errorf("failed collecting variables; got %#v", cfg.A)
+	}
+	if cfg.B != nil {
+		t.Errorf("expected the variables to be listed once per parse; got %#v", cfg.B)
+	}
+
+	cfg = config{}
+	if err := Parse(&cfg, WithDefaultFuncs(map[string]func() (string, error){"changeEnv": changeEnv})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if !reflect.DeepEqual(cfg.B, map[string]string{"X": "b"}) {
+		t.Errorf("expected the variables to be listed again by the next parse; got %#v", cfg.B)
+	}
+}
+
+func TestParseEnviron(t *testing.T) {
+	type config struct {
+		A string `env:"A"`
+		B int    `env:"B"`
+		C string `env:"C"`
+	}
+
+	environ := []string{
+		"A=first",
+		"B=16",
+		"A=second",
+		"C=x=y",
+		"=ignored",
+		"malformed",
+	}
+
+	var cfg config
+	if err := ParseEnviron(environ, &cfg); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	if cfg.A != "second" {
+		t.Errorf("expected the last duplicate to win; expected %#v, got %#v", "second", cfg.A)
+	}
+	if cfg.B != 16 {
+		t.Errorf("failed parsing int; expected %#v, got %#v", 16, cfg.B)
+	}
+	if cfg.C != "x=y" {
+		t.Errorf("failed parsing value containing '='; expected %#v, got %#v", "x=y", cfg.C)
+	}
+}
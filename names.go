@@ -0,0 +1,92 @@
+package babyenv
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// KeyCase determines how the names of variables are cased before they're
+// looked up. See WithKeyCase.
+type KeyCase int
+
+// Available key cases
+const (
+	AsIs KeyCase = iota
+	Upper
+	Lower
+)
+
+// Get the name of the variable for a field, including any prefix.
+func (o *options) envName(info *fieldInfo) string {
+	return o.composeName(info.envVarName)
+}
+
+// Add the prefix to a name and apply the key case.
+func (o *options) composeName(name string) string {
+	name = o.prefix + name
+	switch o.keyCase {
+	case Upper:
+		return strings.ToUpper(name)
+	case Lower:
+		return strings.ToLower(name)
+	default:
+		return name
+	}
+}
+
+// Step into a nested struct field, adding its name to the path and its
+// `envPrefix` tag, if any, to the prefix. The returned function steps back out.
+func (o *options) enterNested(info *fieldInfo) func() {
+	path, prefix := o.path, o.prefix
+	o.path = append(o.path, info.name)
+	o.prefix += info.envPrefix
+	return func() { o.path, o.prefix = path, prefix }
+}
+
+// Report whether a field is read from an environment variable, either because
+// it has an `env` tag or because we're deriving names with WithAutoNames.
+func (o *options) hasEnvVar(info *fieldInfo) bool {
+	return info.tagged || (o.autoNames && info.exported && !info.nested)
+}
+
+// Derive a prefix from the name of a struct type if we've been asked to with
+// WithAutoPrefix. Anonymous types don't get a prefix.
+func (o *options) setAutoPrefix(t reflect.Type) {
+	if !o.autoPrefix {
+		return
+	}
+	name := strings.TrimSuffix(t.Name(), "Config")
+	if name == "" {
+		return
+	}
+	o.prefix = upperSnake(name) + o.prefixSep
+}
+
+// Convert a Go identifier to upper snake case, such as HTTPServer to
+// HTTP_SERVER. Runs of capitals are treated as a single word.
+func upperSnake(s string) string {
+	runes := []rune(s)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// Get the path to a field from the struct being parsed, such as
+// Database.Primary.Port for a field inside nested structs.
+func (o *options) fieldPath(info *fieldInfo) string {
+	if len(o.path) == 0 {
+		return info.name
+	}
+	return strings.Join(o.path, ".") + "." + info.name
+}
@@ -0,0 +1,237 @@
+package babyenv
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type BillingConfig struct {
+	Currency string `env:"CURRENCY"`
+	Retries  int    `env:"RETRIES" default:"3"`
+}
+
+func TestAutoPrefix(t *testing.T) {
+	env := MapLookuper{
+		"BILLING_CURRENCY": "EUR",
+		"CURRENCY":         "USD",
+		"BILLING_RETRIES":  "5",
+	}
+
+	var cfg BillingConfig
+	if err := Parse(&cfg, WithLookuper(env), WithAutoPrefix()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Currency != "EUR" {
+		t.Errorf("expected prefixed variable to be used; expected %#v, got %#v", "EUR", cfg.Currency)
+	}
+	if cfg.Retries != 5 {
+		t.Errorf("expected prefixed variable to be used; expected %d, got %d", 5, cfg.Retries)
+	}
+
+	cfg = BillingConfig{}
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Currency != "USD" {
+		t.Errorf("expected no prefix without WithAutoPrefix; expected %#v, got %#v", "USD", cfg.Currency)
+	}
+}
+
+func TestPrefixSeparator(t *testing.T) {
+	env := MapLookuper{
+		"BILLING.CURRENCY": "EUR",
+		"BILLING_CURRENCY": "USD",
+	}
+
+	var cfg BillingConfig
+	if err := Parse(&cfg, WithLookuper(env), WithAutoPrefix(), WithPrefixSeparator(".")); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Currency != "EUR" {
+		t.Errorf("expected dot-separated prefix to be used; expected %#v, got %#v", "EUR", cfg.Currency)
+	}
+}
+
+func TestKeyCase(t *testing.T) {
+	type config struct {
+		Port int    `env:"PORT"`
+		Host string `env:"host"`
+	}
+
+	env := MapLookuper{"port": "8000", "PORT": "9000", "HOST": "localhost"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithKeyCase(Lower)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Port != 8000 {
+		t.Errorf("expected lower-cased name to be used; expected %d, got %d", 8000, cfg.Port)
+	}
+
+	cfg = config{}
+	if err := Parse(&cfg, WithLookuper(env), WithKeyCase(Upper)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Port != 9000 || cfg.Host != "localhost" {
+		t.Errorf("expected upper-cased names to be used; got %#v", cfg)
+	}
+
+	var billing BillingConfig
+	if err := Parse(&billing, WithLookuper(MapLookuper{"billing_currency": "EUR"}), WithAutoPrefix(), WithKeyCase(Lower)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if billing.Currency != "EUR" {
+		t.Errorf("expected key case to apply to the prefix; expected %#v, got %#v", "EUR", billing.Currency)
+	}
+}
+
+func TestUpperSnake(t *testing.T) {
+	tests := map[string]string{
+		"Billing":    "BILLING",
+		"HTTPServer": "HTTP_SERVER",
+		"ServerHTTP": "SERVER_HTTP",
+		"APIKey":     "API_KEY",
+		"MaxWorkers": "MAX_WORKERS",
+		"HTTPPort":   "HTTP_PORT",
+		"Port2":      "PORT2",
+	}
+	for in, expected := range tests {
+		if actual := upperSnake(in); actual != expected {
+			t.Errorf("upperSnake(%q): expected %q, got %q", in, expected, actual)
+		}
+	}
+}
+
+func TestAutoNames(t *testing.T) {
+	type database struct {
+		Host string
+	}
+	type config struct {
+		MaxWorkers int
+		HTTPPort   int    `default:"8080"`
+		Name       string `env:"APP_NAME"`
+		Skipped    string `env:"-"`
+		Database   database
+	}
+
+	env := MapLookuper{
+		"MAX_WORKERS": "4",
+		"APP_NAME":    "babyenv",
+		"SKIPPED":     "nope",
+		"HOST":        "localhost",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithAutoNames()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+
+	expected := config{
+		MaxWorkers: 4,
+		HTTPPort:   8080,
+		Name:       "babyenv",
+		Database:   database{Host: "localhost"},
+	}
+	if cfg != expected {
+		t.Errorf("failed parsing with derived names; expected %#v, got %#v", expected, cfg)
+	}
+
+	cfg = config{}
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.MaxWorkers != 0 || cfg.Database.Host != "" {
+		t.Errorf("expected untagged fields to be skipped without WithAutoNames; got %#v", cfg)
+	}
+}
+
+func TestStrictUnknown(t *testing.T) {
+	type config struct {
+		Host    string `env:"HOST"`
+		Workers int
+		Skipped string `env:"-"`
+		cache   int
+	}
+
+	env := MapLookuper{"HOST": "localhost", "WORKERS": "4", "SKIPPED": "x", "CACHE": "1"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("expected untagged fields to be ignored by default; got %v", err)
+	}
+
+	var untagged *ErrorUntaggedField
+	err := Parse(&cfg, WithLookuper(env), WithStrictUnknown())
+	if !errors.As(err, &untagged) || untagged.Name != "WORKERS" || untagged.FieldName != "Workers" {
+		t.Errorf("expected an ErrorUntaggedField for WORKERS; got %v", err)
+	}
+
+	delete(env, "WORKERS")
+	if err := Parse(&cfg, WithLookuper(env), WithStrictUnknown()); err != nil {
+		t.Errorf("expected no error without a matching variable; got %v", err)
+	}
+}
+
+func TestEnvPrefix(t *testing.T) {
+	type dbConfig struct {
+		Host string `env:"DB_HOST"`
+		Port int    `env:"DB_PORT" default:"5432"`
+	}
+	type config struct {
+		Primary dbConfig  `envPrefix:"PRIMARY_"`
+		Replica *dbConfig `envPrefix:"REPLICA_"`
+		Host    string    `env:"DB_HOST"`
+	}
+
+	env := MapLookuper{
+		"PRIMARY_DB_HOST": "primary",
+		"REPLICA_DB_HOST": "replica",
+		"REPLICA_DB_PORT": "5433",
+		"DB_HOST":         "unprefixed",
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env), WithUniqueNames()); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if expected := (dbConfig{"primary", 5432}); cfg.Primary != expected {
+		t.Errorf("failed parsing prefixed struct; expected %#v, got %#v", expected, cfg.Primary)
+	}
+	if expected := (dbConfig{"replica", 5433}); cfg.Replica == nil || *cfg.Replica != expected {
+		t.Errorf("failed parsing prefixed struct pointer; expected %#v, got %#v", expected, cfg.Replica)
+	}
+	if cfg.Host != "unprefixed" {
+		t.Errorf("expected the prefix not to apply outside the nested struct; got %#v", cfg.Host)
+	}
+
+	fields, err := Describe(&cfg)
+	if err != nil {
+		t.Errorf("error while describing: %v", err)
+		return
+	}
+	var names []string
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	expected := []string{"PRIMARY_DB_HOST", "PRIMARY_DB_PORT", "REPLICA_DB_HOST", "REPLICA_DB_PORT", "DB_HOST"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("unexpected described names; expected %#v, got %#v", expected, names)
+	}
+
+	cfg = config{}
+	err = Parse(&cfg, WithLookuper(MapLookuper{"REPLICA_DB_PORT": "x"}))
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) || invalid.Name != "REPLICA_DB_PORT" {
+		t.Errorf("expected an ErrorInvalidValue naming the prefixed variable; got %v", err)
+	}
+}
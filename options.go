@@ -0,0 +1,582 @@
+package babyenv
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Option is used to configure the behavior of Parse.
+type Option func(*options)
+
+type options struct {
+	lookuper           Lookuper
+	caseInsensitive    bool
+	collectErrors      bool
+	respectExisting    bool
+	allocateNilStructs bool
+	interfaceInference bool
+	validateDefaults   bool
+	unquote            bool
+	csvLists           bool
+	expandDefaults     bool
+	expandValues       bool
+	skipUnsupported    bool
+	autoPrefix         bool
+	autoNames          bool
+	strictTags         bool
+	strictUnknown      bool
+	validateTypes      bool
+	uniqueNames        bool
+	allowUnexported    bool
+	lenient            bool
+	requireFiles       bool
+	envFallback        bool
+	reloading          bool
+	prefix             string
+	prefixSep          string
+	unsetToken         string
+	keyCase            KeyCase
+	defaults           map[string]string
+	defaultFuncs       map[string]func() (string, error)
+	transforms         map[string]func(string) string
+	typeParsers        map[reflect.Type]func(string) (interface{}, error)
+	requiredGroups     map[string]bool
+	nullTokens         map[string]bool
+	trueWords          []string
+	falseWords         []string
+	deprecationHandler func(field, oldName, newName string)
+	errorFormatter     func([]FieldError) error
+	audit              io.Writer
+	logger             *slog.Logger
+	flags              *flag.FlagSet
+	report             *Report
+
+	// Struct types we're currently inside of while recursing
+	visiting map[reflect.Type]bool
+
+	// Names of the struct fields we're currently inside of while recursing
+	path []string
+
+	// Values of the fields parsed so far, keyed by variable name
+	resolved map[string]string
+
+	// Values of the variables matched by patterns such as ROUTE_*, keyed by
+	// pattern and then by variable name
+	matched map[string]map[string]string
+
+	// Values of the flags that were set, keyed by flag name
+	flagsSet map[string]string
+
+	// Members of the required groups seen so far, and the order in which
+	// the groups were seen
+	groupMembers map[string][]groupMember
+	groupNames   []string
+
+	// Defaults that have been validated, shared by every call made with
+	// the same options
+	checkedDefaults *sync.Map
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		lookuper:  osLookuper{},
+		prefixSep: "_",
+		visiting:  make(map[reflect.Type]bool),
+		resolved:  make(map[string]string),
+		matched:   make(map[string]map[string]string),
+
+		groupMembers:    make(map[string][]groupMember),
+		checkedDefaults: new(sync.Map),
+		transforms: map[string]func(string) string{
+			"lower": strings.ToLower,
+			"upper": strings.ToUpper,
+			"trim":  strings.TrimSpace,
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Copy options for use in a single call, so state built up while parsing
+// isn't shared between calls.
+func (o *options) clone() *options {
+	c := *o
+	c.visiting = make(map[reflect.Type]bool)
+	c.resolved = make(map[string]string)
+	c.matched = make(map[string]map[string]string)
+	c.groupMembers = make(map[string][]groupMember)
+	c.groupNames = nil
+	c.path = nil
+	return &c
+}
+
+// WithLookuper sets the source of environment variables. By default the
+// process environment is used.
+func WithLookuper(l Lookuper) Option {
+	return func(o *options) {
+		o.lookuper = l
+	}
+}
+
+// WithCaseInsensitive makes lookups fall back to a case-insensitive match when
+// there's no variable with the exact name given in the `env` tag. If more than
+// one variable matches an ErrorAmbiguousName is returned. The Lookuper must
+// implement Enumerator.
+func WithCaseInsensitive() Option {
+	return func(o *options) {
+		o.caseInsensitive = true
+	}
+}
+
+// WithCollectErrors continues parsing after an error is encountered rather
+// than stopping at the first one. All errors are returned together in an
+// ErrorList.
+func WithCollectErrors() Option {
+	return func(o *options) {
+		o.collectErrors = true
+	}
+}
+
+// WithErrorFormatter sets a function that turns the errors in an ErrorList,
+// such as those collected with WithCollectErrors, into the error that's
+// returned, so they can be presented however suits. Other errors are returned
+// as they are. ParseLenient always returns the errors themselves.
+//
+//     babyenv.WithErrorFormatter(func(errs []babyenv.FieldError) error {
+//         return fmt.Errorf("%d bad variables", len(errs))
+//     })
+func WithErrorFormatter(fn func([]FieldError) error) Option {
+	return func(o *options) {
+		o.errorFormatter = fn
+	}
+}
+
+// WithRespectExistingValues leaves fields that already hold a non-zero value
+// untouched when their environment variable is unset and there's no `default`
+// tag. This allows defaults to be expressed in Go:
+//
+//     cfg := config{Workers: 16}
+//     err := babyenv.Parse(&cfg, babyenv.WithRespectExistingValues())
+func WithRespectExistingValues() Option {
+	return func(o *options) {
+		o.respectExisting = true
+	}
+}
+
+// WithAllocateNilStructs always allocates nil pointers to nested structs.
+// By default they're left nil if none of their fields were given a value,
+// which is handy for optional groups of config.
+func WithAllocateNilStructs() Option {
+	return func(o *options) {
+		o.allocateNilStructs = true
+	}
+}
+
+// WithInterfaceInference allows interface{} and map[string]interface{} fields
+// to be parsed. The type of the value is inferred: if it parses as an int the
+// field is set to an int, otherwise a float64, a bool, or finally a string.
+// Since inference can be surprising it's off by default, and such fields are
+// unsupported.
+func WithInterfaceInference() Option {
+	return func(o *options) {
+		o.interfaceInference = true
+	}
+}
+
+// WithValidateDefaults checks that every `default` tag can be parsed into its
+// field's type, even when the environment variable is set and the default
+// won't be used. This catches misconfigured defaults in tests and CI
+// regardless of the environment. Defaults are checked before any fields are
+// set, and a Parser only checks those of each struct type once, however many
+// times it parses the type. Defaults that are computed, or that refer to other
+// fields or variables, can't be checked ahead of time and are skipped.
+func WithValidateDefaults() Option {
+	return func(o *options) {
+		o.validateDefaults = true
+	}
+}
+
+// WithValidateTypes checks that the types of all fields are supported before
+// reading the environment, so an unsupported type is reported without any
+// fields having been set. See ValidateStruct.
+func WithValidateTypes() Option {
+	return func(o *options) {
+		o.validateTypes = true
+	}
+}
+
+// WithAllowUnexported allows unexported fields with an `env` tag to be set,
+// rather than returning an ErrorUnsettable. This uses package unsafe to get
+// around the usual rules, so it's best kept to structs you control. Untagged
+// unexported structs are still not recursed into.
+func WithAllowUnexported() Option {
+	return func(o *options) {
+		o.allowUnexported = true
+	}
+}
+
+// WithUniqueNames returns an ErrorList of ErrorDuplicateName before parsing if
+// more than one field reads the same variable, after prefixes are applied,
+// which is usually a copy-and-paste mistake.
+func WithUniqueNames() Option {
+	return func(o *options) {
+		o.uniqueNames = true
+	}
+}
+
+// WithRequiredGroups makes the named groups all or nothing: if any variable
+// in a group is set, they all have to be. Fields are placed in a group with
+// the `group` tag:
+//
+//     User string `env:"DB_USER" group:"db"`
+//     Pass string `env:"DB_PASS" group:"db"`
+//
+// An ErrorList of ErrorIncompleteGroup naming the missing variables is
+// returned for groups that are only partly set.
+func WithRequiredGroups(groups ...string) Option {
+	return func(o *options) {
+		if o.requiredGroups == nil {
+			o.requiredGroups = make(map[string]bool)
+		}
+		for _, g := range groups {
+			o.requiredGroups[g] = true
+		}
+	}
+}
+
+// WithUnsetToken sets a value which, when a variable holds it exactly, gives
+// the field its zero value, or a nil pointer, ignoring any default. This lets
+// deployments clear a value that would otherwise be defaulted:
+//
+//     err := babyenv.Parse(&cfg, babyenv.WithUnsetToken("__UNSET__"))
+//
+// Unlike null tokens, the unset token applies to fields of any type.
+func WithUnsetToken(token string) Option {
+	return func(o *options) {
+		o.unsetToken = token
+	}
+}
+
+// WithBoolWords sets extra words to accept for true and false in bool fields,
+// pointers to them, and lists of them, such as for deployments in other
+// languages. Words are matched case-insensitively, and the usual values, like
+// "true" and "0", are still accepted.
+//
+//     err := babyenv.Parse(&cfg, babyenv.WithBoolWords([]string{"ja"}, []string{"nein"}))
+func WithBoolWords(trueWords, falseWords []string) Option {
+	return func(o *options) {
+		o.trueWords = trueWords
+		o.falseWords = falseWords
+	}
+}
+
+// WithNullTokens sets values which, when given for a pointer field, leave the
+// pointer nil rather than pointing at the parsed value. This allows "no value"
+// to be expressed explicitly in the environment:
+//
+//     err := babyenv.Parse(&cfg, babyenv.WithNullTokens("null", "nil"))
+//
+// Null tokens only apply to pointer fields.
+func WithNullTokens(tokens ...string) Option {
+	return func(o *options) {
+		if o.nullTokens == nil {
+			o.nullTokens = make(map[string]bool)
+		}
+		for _, t := range tokens {
+			o.nullTokens[t] = true
+		}
+	}
+}
+
+// WithCSVLists reads slices and arrays as CSV records, so elements containing
+// the separator can be quoted:
+//
+//     HOSTS=a,"b,c",d // []string{"a", "b,c", "d"}
+//
+// Quotes are handled as in encoding/csv. Lists separated by newlines, or by
+// more than one character, are split as usual.
+func WithCSVLists() Option {
+	return func(o *options) {
+		o.csvLists = true
+	}
+}
+
+// WithUnquote strips matching single or double quotes from around the values
+// of string and []byte fields. Double quoted values may contain escape
+// sequences, such as \n, in the same way as Go string literals, while single
+// quoted values are taken literally. Unbalanced quotes result in an error.
+func WithUnquote() Option {
+	return func(o *options) {
+		o.unquote = true
+	}
+}
+
+// WithRequiredFiles makes ParseFiles return an error if any of its files
+// don't exist, rather than skipping them.
+func WithRequiredFiles() Option {
+	return func(o *options) {
+		o.requireFiles = true
+	}
+}
+
+// WithEnvFallback makes ParseFiles look up variables that aren't defined in
+// any of its files in the process environment, or the Lookuper given with
+// WithLookuper.
+func WithEnvFallback() Option {
+	return func(o *options) {
+		o.envFallback = true
+	}
+}
+
+// WithAudit writes a line to w for each field parsed describing the value it
+// was given and where it came from, which is handy for debugging precedence:
+//
+//     NAME=Jane (env)
+//     PORT=8000 (default)
+//     DEBUG= (zero)
+//
+// The values of secret fields are redacted.
+func WithAudit(w io.Writer) Option {
+	return func(o *options) {
+		o.audit = w
+	}
+}
+
+// WithLogger logs how each field was resolved to l at debug level, including
+// its value and where it came from, while reading a deprecated variable is
+// logged at warn level. The values of secret fields are redacted.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithExpandDefaults expands shell-style variable references in `default`
+// tags, so defaults can be derived from other variables:
+//
+//     ConfigDir string `env:"CONFIG_DIR" default:"${XDG_CONFIG_HOME:-$HOME/.config}/app"`
+//
+// $VAR, ${VAR} and ${VAR:-fallback} are supported, the fallback being used
+// when VAR is unset or empty. Use $$ for a literal dollar sign. Values read
+// from the environment are left alone unless WithExpandValues is also given.
+func WithExpandDefaults() Option {
+	return func(o *options) {
+		o.expandDefaults = true
+	}
+}
+
+// WithShellDefaults is the same as WithExpandDefaults.
+func WithShellDefaults() Option {
+	return WithExpandDefaults()
+}
+
+// WithExpandValues expands shell-style variable references, in the same forms
+// as WithExpandDefaults, in the values of environment variables. It doesn't
+// affect defaults, so values can be expanded while defaults are taken
+// literally, or the other way around.
+func WithExpandValues() Option {
+	return func(o *options) {
+		o.expandValues = true
+	}
+}
+
+// WithSkipUnsupported leaves fields of unsupported types untouched rather
+// than returning an ErrorUnsupportedType, which is handy for large shared
+// structs.
+func WithSkipUnsupported() Option {
+	return func(o *options) {
+		o.skipUnsupported = true
+	}
+}
+
+// WithAutoPrefix prefixes the names of variables with one derived from the
+// name of the struct type being parsed. A trailing "Config" is dropped and the
+// rest is converted to upper snake case, so the fields of a BillingConfig are
+// read from variables beginning with BILLING_:
+//
+//     type BillingConfig struct {
+//         Currency string `env:"CURRENCY"` // BILLING_CURRENCY
+//     }
+//
+// Nested structs share the prefix of the struct passed to Parse.
+func WithAutoPrefix() Option {
+	return func(o *options) {
+		o.autoPrefix = true
+	}
+}
+
+// WithPrefixSeparator sets the string placed between a prefix and the rest of
+// a variable's name, which is an underscore by default. With a dot, the fields
+// of a BillingConfig are read from variables such as BILLING.CURRENCY.
+func WithPrefixSeparator(sep string) Option {
+	return func(o *options) {
+		o.prefixSep = sep
+	}
+}
+
+// WithAutoNames reads exported fields without an `env` tag from variables
+// named after the field in upper snake case, so MaxWorkers is read from
+// MAX_WORKERS and HTTPPort from HTTP_PORT. Untagged structs are still parsed
+// as nested structs, and fields tagged `env:"-"` are still skipped.
+func WithAutoNames() Option {
+	return func(o *options) {
+		o.autoNames = true
+	}
+}
+
+// WithStrictUnknown returns an ErrorUntaggedField when a variable is set that
+// matches the name an exported field without an `env` tag would have with
+// WithAutoNames, such as WORKERS for a field named Workers. This catches
+// forgotten tags. It has no effect with WithAutoNames, since such fields are
+// read anyway.
+func WithStrictUnknown() Option {
+	return func(o *options) {
+		o.strictUnknown = true
+	}
+}
+
+// WithStrictTags returns an error for fields whose tags contradict each other,
+// such as a required field with a default, which would otherwise be silently
+// ignored.
+func WithStrictTags() Option {
+	return func(o *options) {
+		o.strictTags = true
+	}
+}
+
+// WithFlagOverrides lets command-line flags override the environment. Fields
+// with a `flag` tag take their value from the named flag in fs if it was set,
+// ahead of both their environment variable and their default:
+//
+//     Port int `env:"PORT" flag:"port" default:"8000"`
+//
+// Only flags that were explicitly given are used, so the flag's own default
+// doesn't mask the environment. The value is parsed in the same way as an
+// environment variable, so fs should be parsed before the struct.
+func WithFlagOverrides(fs *flag.FlagSet) Option {
+	return func(o *options) {
+		o.flags = fs
+	}
+}
+
+// WithKeyCase converts the names of variables to upper or lower case before
+// they're looked up, for systems with their own conventions. The case is
+// applied after any prefix is added, so with Lower the fields of a
+// BillingConfig are read from variables such as billing_currency when
+// WithAutoPrefix is used. The default is AsIs.
+func WithKeyCase(c KeyCase) Option {
+	return func(o *options) {
+		o.keyCase = c
+	}
+}
+
+// WithDeprecationHandler registers a function that's called whenever a value
+// is read from one of the deprecated names listed in a field's `deprecated`
+// tag. It's useful for logging a notice during a rename.
+func WithDeprecationHandler(fn func(field, oldName, newName string)) Option {
+	return func(o *options) {
+		o.deprecationHandler = fn
+	}
+}
+
+// WithDefaults provides defaults in code, keyed by variable name, which is
+// handy when they differ between environments. They're used when a variable
+// is unset, and take precedence over `default` tags:
+//
+//     err := babyenv.Parse(&cfg, babyenv.WithDefaults(map[string]string{
+//         "LOG_LEVEL": "debug",
+//     }))
+//
+// Names include any prefix. Defaults are otherwise treated like those given in
+// tags, so they can be computed with an @ and so on.
+func WithDefaults(defaults map[string]string) Option {
+	return func(o *options) {
+		if o.defaults == nil {
+			o.defaults = make(map[string]string)
+		}
+		for name, val := range defaults {
+			o.defaults[name] = val
+		}
+	}
+}
+
+// WithDefaultFuncs registers functions for computing default values at
+// runtime. A function is referenced from a `default` tag by prefixing its name
+// with an @:
+//
+//     DataDir string `env:"DATA_DIR" default:"@dataDir"`
+//
+// The returned string is then parsed like any other default value.
+func WithDefaultFuncs(funcs map[string]func() (string, error)) Option {
+	return func(o *options) {
+		if o.defaultFuncs == nil {
+			o.defaultFuncs = make(map[string]func() (string, error))
+		}
+		for name, fn := range funcs {
+			o.defaultFuncs[name] = fn
+		}
+	}
+}
+
+// Run the default func registered under the given name.
+func (o *options) computeDefault(name string) (string, error) {
+	fn, ok := o.defaultFuncs[name]
+	if !ok {
+		return "", fmt.Errorf("no default func named %q", name)
+	}
+	return fn()
+}
+
+// WithTransforms registers functions for preprocessing values before they're
+// parsed. A transform is applied to a field by naming it in the `transform`
+// tag:
+//
+//     Host string `env:"HOST" transform:"stripScheme"`
+//
+// The transforms "lower", "upper" and "trim" are available by default.
+func WithTransforms(transforms map[string]func(string) string) Option {
+	return func(o *options) {
+		for name, fn := range transforms {
+			o.transforms[name] = fn
+		}
+	}
+}
+
+// WithTypeParser registers a function for parsing values of the given type,
+// which is handy for types you don't own. It takes precedence over the
+// built-in handling of the type:
+//
+//     babyenv.WithTypeParser(reflect.TypeOf(money.Amount{}), func(s string) (interface{}, error) {
+//         return money.Parse(s)
+//     })
+//
+// The function must return a value assignable to the type, or nil for its
+// zero value.
+func WithTypeParser(t reflect.Type, parse func(string) (interface{}, error)) Option {
+	return func(o *options) {
+		if o.typeParsers == nil {
+			o.typeParsers = make(map[reflect.Type]func(string) (interface{}, error))
+		}
+		o.typeParsers[t] = parse
+	}
+}
+
+// Apply the comma-separated list of named transforms to a value, in order.
+func (o *options) transform(val, names string) (string, error) {
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		fn, ok := o.transforms[name]
+		if !ok {
+			return "", fmt.Errorf("no transform named %q", name)
+		}
+		val = fn(val)
+	}
+	return val, nil
+}
@@ -0,0 +1,23 @@
+package babyenv
+
+// Parser parses structs with a fixed set of options. It can be configured
+// once and reused for any number of structs. A Parser can be used from several
+// goroutines at once, provided its Lookuper can.
+//
+//     p := babyenv.New(babyenv.WithLookuper(env), babyenv.WithCollectErrors())
+//     err := p.Parse(&server)
+//     err = p.Parse(&db)
+type Parser struct {
+	opts *options
+}
+
+// New returns a Parser configured with the given options.
+func New(opts ...Option) *Parser {
+	return &Parser{opts: newOptions(opts)}
+}
+
+// Parse parses a struct in the same way as the package-level Parse, using the
+// Parser's options.
+func (p *Parser) Parse(cfg interface{}) error {
+	return parse(cfg, p.opts.clone())
+}
@@ -0,0 +1,89 @@
+package babyenv
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	type server struct {
+		Port int `env:"PORT" default:"8000"`
+	}
+	type database struct {
+		URL  string `env:"DATABASE_URL,required"`
+		Pool int    `env:"POOL_SIZE" default:"4"`
+	}
+
+	p := New(WithLookuper(MapLookuper{
+		"PORT":         "9000",
+		"DATABASE_URL": "postgres://localhost",
+	}))
+
+	var srv server
+	if err := p.Parse(&srv); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if srv.Port != 9000 {
+		t.Errorf("failed parsing first struct; expected %d, got %d", 9000, srv.Port)
+	}
+
+	var db database
+	if err := p.Parse(&db); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if db.URL != "postgres://localhost" || db.Pool != 4 {
+		t.Errorf("failed parsing second struct; got %#v", db)
+	}
+}
+
+func TestParserConcurrentDefaults(t *testing.T) {
+	type config struct {
+		Port    int    `env:"PORT" default:"8000"`
+		Host    string `env:"HOST" default:"localhost"`
+		Workers int    `env:"WORKERS" default:"notanint"`
+	}
+
+	p := New(WithLookuper(MapLookuper{"WORKERS": "4"}), WithValidateDefaults())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var cfg config
+			var invalid *ErrorInvalidValue
+			if err := p.Parse(&cfg); !errors.As(err, &invalid) {
+				t.Errorf("expected an ErrorInvalidValue for the bad default; got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParserEagerDefaults(t *testing.T) {
+	type limits struct {
+		Burst int `env:"BURST" default:"lots"`
+	}
+	type config struct {
+		Host   string `env:"HOST"`
+		Limits limits
+	}
+
+	p := New(WithLookuper(MapLookuper{"HOST": "localhost", "BURST": "10"}), WithValidateDefaults())
+
+	var cfg config
+	err := p.Parse(&cfg)
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an ErrorInvalidValue for the bad default; got %v", err)
+	}
+	if cfg.Host != "" {
+		t.Errorf("expected no fields to be set; got %#v", cfg)
+	}
+	if err := p.Parse(&cfg); !errors.As(err, &invalid) {
+		t.Errorf("expected the same error parsing again; got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package babyenv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Units understood by the `unit` tag for durations stored as plain numbers
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// Multipliers for the suffixes understood by `unit:"bytes"`. Longer suffixes
+// come first so "KiB" isn't mistaken for "B".
+var byteSuffixes = []struct {
+	suffix string
+	n      int64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"KB", 1e3},
+	{"MB", 1e6},
+	{"GB", 1e9},
+	{"TB", 1e12},
+	{"B", 1},
+}
+
+// Convert a value expressed in the unit named in a field's `unit` tag into a
+// plain number that the field's setter can parse. Besides "bytes" and
+// "percent", the duration units ns, us, ms, s, m and h are understood. Empty
+// values are left alone.
+func convertUnit(s, unit string, t reflect.Type) (string, error) {
+	if s == "" {
+		return s, nil
+	}
+
+	switch unit {
+	case "bytes":
+		return parseBytes(s)
+	case "percent":
+		return parsePercent(s, t)
+	}
+
+	if d, ok := durationUnits[unit]; ok {
+		return parseDurationUnit(s, unit, d, t)
+	}
+	return "", fmt.Errorf("unknown unit %q", unit)
+}
+
+// Parse a size such as 512MB or 1GiB into a number of bytes. A number without
+// a suffix is taken to be bytes already.
+func parseBytes(s string) (string, error) {
+	num, mult := s, int64(1)
+	for _, b := range byteSuffixes {
+		if strings.HasSuffix(s, b.suffix) {
+			num, mult = strings.TrimSpace(s[:len(s)-len(b.suffix)]), b.n
+			break
+		}
+	}
+
+	n, err := parseInt(num, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid byte size %q", s)
+	}
+	if n > math.MaxInt64/mult || n < math.MinInt64/mult {
+		return "", errors.New("byte size out of range")
+	}
+	return strconv.FormatInt(n*mult, 10), nil
+}
+
+// Parse a percentage such as 50%. Floats are given a fraction, so 50% becomes
+// 0.5, while integers are given the number of percent.
+func parsePercent(s string, t reflect.Type) (string, error) {
+	num := strings.TrimSpace(strings.TrimSuffix(s, "%"))
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64 {
+		return num, nil
+	}
+
+	f, err := parseFloat(num, 64)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(f/100, 'g', -1, 64), nil
+}
+
+// Parse a duration such as 2s into a number of the given unit. A plain number
+// is taken to be in that unit already. Integers must hold a whole number of
+// the unit, while floats may hold a fraction.
+func parseDurationUnit(s, name string, unit time.Duration, t reflect.Type) (string, error) {
+	if _, err := parseFloat(s, 64); err == nil {
+		return s, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return "", err
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64 {
+		return strconv.FormatFloat(float64(d)/float64(unit), 'g', -1, 64), nil
+	}
+	if d%unit != 0 {
+		return "", fmt.Errorf("%s is not a whole number of %s", s, name)
+	}
+	return strconv.FormatInt(int64(d/unit), 10), nil
+}
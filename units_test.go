@@ -0,0 +1,124 @@
+package babyenv
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnits(t *testing.T) {
+	type config struct {
+		Memory    int64   `env:"MEMORY_LIMIT" unit:"bytes"`
+		Cache     int64   `env:"CACHE_SIZE" unit:"bytes" default:"1GiB"`
+		CPU       int     `env:"CPU" unit:"percent"`
+		Threshold float64 `env:"THRESHOLD" unit:"percent"`
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{
+		"MEMORY_LIMIT": "512MB",
+		"CPU":          "50%",
+		"THRESHOLD":    "50%",
+	})); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.Memory != 512000000 {
+		t.Errorf("failed parsing byte size; expected %d, got %d", 512000000, cfg.Memory)
+	}
+	if cfg.Cache != 1<<30 {
+		t.Errorf("failed parsing byte size default; expected %d, got %d", 1<<30, cfg.Cache)
+	}
+	if cfg.CPU != 50 {
+		t.Errorf("failed parsing percentage into int; expected %d, got %d", 50, cfg.CPU)
+	}
+	if cfg.Threshold != 0.5 {
+		t.Errorf("failed parsing percentage into float; expected %v, got %v", 0.5, cfg.Threshold)
+	}
+}
+
+func TestDurationUnits(t *testing.T) {
+	type config struct {
+		Timeout  int     `env:"TIMEOUT" unit:"ms"`
+		Interval int64   `env:"INTERVAL" unit:"s" default:"2m"`
+		Delay    float64 `env:"DELAY" unit:"s"`
+	}
+
+	for _, timeout := range []string{"2000", "2s"} {
+		var cfg config
+		if err := Parse(&cfg, WithLookuper(MapLookuper{"TIMEOUT": timeout, "DELAY": "1500ms"})); err != nil {
+			t.Errorf("error while parsing: %v", err)
+			return
+		}
+		if cfg.Timeout != 2000 {
+			t.Errorf("failed parsing %q into milliseconds; expected %d, got %d", timeout, 2000, cfg.Timeout)
+		}
+		if cfg.Interval != 120 {
+			t.Errorf("failed parsing default into seconds; expected %d, got %d", 120, cfg.Interval)
+		}
+		if cfg.Delay != 1.5 {
+			t.Errorf("failed parsing fractional seconds; expected %v, got %v", 1.5, cfg.Delay)
+		}
+	}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(MapLookuper{"TIMEOUT": "1500us"})); err == nil {
+		t.Error("expected an error parsing a fraction of a millisecond into an int")
+	}
+}
+
+func TestInvalidUnits(t *testing.T) {
+	type config struct {
+		Memory int64 `env:"UNITS_MEMORY_LIMIT" unit:"bytes"`
+	}
+
+	var cfg config
+	err := Parse(&cfg, WithLookuper(MapLookuper{"UNITS_MEMORY_LIMIT": "512XB"}))
+
+	var invalid *ErrorInvalidValue
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected an ErrorInvalidValue; got %v", err)
+	}
+	if !strings.Contains(err.Error(), "UNITS_MEMORY_LIMIT") {
+		t.Errorf("expected error to name the variable; got %v", err)
+	}
+}
+
+func TestThousandsSeparators(t *testing.T) {
+	type config struct {
+		A int      `env:"A" thousands:","`
+		B float64  `env:"B" thousands:","`
+		C *int64   `env:"C" thousands:"."`
+		D []string `env:"D" thousands:","`
+		E float64  `env:"E" thousands:"."`
+	}
+
+	env := MapLookuper{"A": "1,000,000", "B": "-12,345.5", "C": "2.500", "E": "1.234,5", "D": "1,000"}
+
+	var cfg config
+	if err := Parse(&cfg, WithLookuper(env)); err != nil {
+		t.Errorf("error while parsing: %v", err)
+		return
+	}
+	if cfg.A != 1000000 {
+		t.Errorf("failed parsing int with thousands separators; expected %#v, got %#v", 1000000, cfg.A)
+	}
+	if cfg.B != -12345.5 {
+		t.Errorf("failed parsing float with thousands separators; expected %#v, got %#v", -12345.5, cfg.B)
+	}
+	if cfg.C == nil || *cfg.C != 2500 {
+		t.Errorf("failed parsing *int64 with thousands separators; got %#v", cfg.C)
+	}
+	if cfg.E != 1234.5 {
+		t.Errorf("failed parsing float with a comma for a decimal mark; expected %#v, got %#v", 1234.5, cfg.E)
+	}
+	if len(cfg.D) != 2 {
+		t.Errorf("expected lists to be split as usual; got %#v", cfg.D)
+	}
+
+	for _, val := range []string{"1,00", "1,0000", ",100", "1,,000"} {
+		if err := Parse(&cfg, WithLookuper(MapLookuper{"A": val})); err == nil {
+			t.Errorf("expected an error parsing %#v", val)
+		}
+	}
+}